@@ -0,0 +1,14 @@
+//go:build googlecharts
+
+package gauth
+
+// BarcodeURL returns a Google Charts URL rendering OTPAuthURL as a QR code.
+//
+// Deprecated: the Google Charts QR API is shut down for new users and
+// sends the secret to a third party. Render OTPAuthURL with the gauth/qr
+// package instead. BarcodeURL is only built with the googlecharts tag.
+func (c Config) BarcodeURL(user, domain, secret string) string {
+	optURL := c.OTPAuthURL(user, domain, secret)
+	url := "https://www.google.com/chart?chs=200x200&chld=M|0&cht=qr&chl=" + optURL
+	return url
+}
@@ -0,0 +1,10 @@
+#!/bin/sh
+# Builds ./gauth with the version, commit and build date printed by
+# gauth --version.
+set -e
+
+version=$(git describe --tags --always --dirty 2>/dev/null || echo dev)
+commit=$(git rev-parse HEAD 2>/dev/null || echo unknown)
+date=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+
+go build -ldflags "-X main.version=$version -X main.commit=$commit -X main.buildDate=$date" -o gauth ./cmd/gauth
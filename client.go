@@ -0,0 +1,35 @@
+package gauth
+
+// Client generates and verifies time-based codes with a fixed Config.
+// Create it once and reuse it:
+//
+//	c := gauth.NewClient(gauth.WithPeriod(30))
+//	code, err := c.Generate(secret)
+//	ok, err := c.Verify(secret, code)
+type Client struct {
+	Config Config
+}
+
+// NewClient returns a Client using DefaultConfig changed by opts.
+func NewClient(opts ...Option) *Client {
+	return &Client{Config: newConfig(opts)}
+}
+
+// Generate returns the code for secret at the current time of the
+// client's time source.
+func (cl *Client) Generate(secret string) (string, error) {
+	return cl.Config.GenerateTimeBasedAt(secret, cl.Config.now())
+}
+
+// Verify reports whether code is the code for secret at the current
+// time, or within the client's window of time steps around it. A
+// malformed code is reported as ErrInvalidCode and, with a replay
+// store, a code already used as ErrCodeReused.
+func (cl *Client) Verify(secret, code string) (bool, error) {
+	if cl.Config.Replay != nil {
+		_, ok, err := cl.Config.VerifyTimeBasedOnce(cl.Config.Replay, ReplayKey(secret), secret, code, cl.Config.Window, cl.Config.now())
+		return ok, err
+	}
+	_, ok, err := cl.Config.VerifyTimeBasedAt(secret, code, cl.Config.Window, cl.Config.now())
+	return ok, err
+}
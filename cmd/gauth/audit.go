@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// auditRecord is a line of the --audit-log file, written for every
+// --verify. The code itself is never recorded.
+type auditRecord struct {
+	Time    string `json:"time"`
+	Account string `json:"account,omitempty"`
+	Code    string `json:"code"`
+	Offset  *int   `json:"offset,omitempty"` // time step offset of a match
+	Result  string `json:"result"`
+}
+
+// newAuditRecord describes a verification of account at t that
+// succeeded at offset when ok is set.
+func newAuditRecord(t time.Time, account string, offset int, ok bool) auditRecord {
+	record := auditRecord{
+		Time:    t.UTC().Format(time.RFC3339),
+		Account: account,
+		Code:    "***",
+		Result:  "failure",
+	}
+	if ok {
+		record.Offset = &offset
+		record.Result = "success"
+	}
+	return record
+}
+
+// appendAudit appends record to the audit log filename as a JSON line,
+// creating the file when it does not exist yet.
+func appendAudit(filename string, record auditRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readAudit reads the records of the audit log filename.
+func readAudit(filename string) ([]auditRecord, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []auditRecord
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record auditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", filename, n, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// auditTable returns the rows of a table of records, headed by the
+// column names.
+func auditTable(records []auditRecord) [][]string {
+	rows := [][]string{{"Time", "Account", "Code", "Offset", "Result"}}
+	for _, record := range records {
+		offset := ""
+		if record.Offset != nil {
+			offset = strconv.Itoa(*record.Offset)
+		}
+		rows = append(rows, []string{record.Time, record.Account, record.Code, offset, record.Result})
+	}
+	return rows
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// backupSuffix is appended to the name of a secrets file to keep its
+// previous contents whenever gauth rewrites it.
+const backupSuffix = ".bak"
+
+// gpgCommand is the GnuPG program used for .gpg and .asc backups.
+var gpgCommand = "gpg"
+
+// keepBackup copies filename, as stored on disk, to filename.bak. A
+// missing file has nothing to back up. When passphrase is set and the
+// file is still plaintext, the backup is encrypted with it, so that
+// encrypting a file leaves no readable copy next to it.
+func keepBackup(filename string, passphrase []byte) error {
+	content, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if passphrase != nil && !isEncrypted(content) {
+		content, err = encryptContent(content, passphrase)
+		if err != nil {
+			return err
+		}
+	}
+	if err := writeFileAtomic(filename+backupSuffix, content); err != nil {
+		return fmt.Errorf("can not back up %s: %w", filename, err)
+	}
+	return nil
+}
+
+// isGPGBackup reports whether a backup file is handled by GnuPG rather
+// than the built-in AES-GCM encryption.
+func isGPGBackup(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gpg", ".asc":
+		return true
+	}
+	return false
+}
+
+// backupFile writes an encrypted copy of filename to output. Outputs
+// ending in .gpg or .asc are encrypted with gpg --symmetric; otherwise
+// the built-in encryption of --encrypt is used, keeping files that are
+// already encrypted as they are.
+func backupFile(filename, output string) error {
+	if _, err := os.Stat(output); err == nil {
+		return fmt.Errorf("%s already exists", output)
+	}
+	if dryRun {
+		// The encrypted backup can not be shown as a diff.
+		if _, err := readFile(filename); err != nil {
+			return err
+		}
+		fmt.Printf("would write encrypted backup of %s to %s\n", filename, output)
+		dryRunChanged = true
+		return nil
+	}
+	if isGPGBackup(output) {
+		content, _, err := readSecretsFile(filename)
+		if err != nil {
+			return err
+		}
+		args := []string{"--symmetric", "--output", output}
+		if strings.EqualFold(filepath.Ext(output), ".asc") {
+			args = append(args, "--armor")
+		}
+		cmd := exec.Command(gpgCommand, args...)
+		cmd.Stdin = bytes.NewReader(content)
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s: %w", gpgCommand, err)
+		}
+		return nil
+	}
+
+	content, err := readFile(filename)
+	if err != nil {
+		return err
+	}
+	if !isEncrypted(content) {
+		passphrase, err := readNewPassphrase()
+		if err != nil {
+			return err
+		}
+		content, err = encryptContent(content, passphrase)
+		if err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(output, content)
+}
+
+// restoreFile decrypts the backup written by backupFile and replaces
+// filename with the plain secrets, keeping the current file as a
+// backup.
+func restoreFile(backup, filename string) error {
+	var content []byte
+	var err error
+	if isGPGBackup(backup) {
+		if _, err := os.Stat(backup); err != nil {
+			return err
+		}
+		cmd := exec.Command(gpgCommand, "--decrypt", backup)
+		cmd.Stderr = os.Stderr
+		content, err = cmd.Output()
+		if err != nil {
+			return fmt.Errorf("%s: %w", gpgCommand, err)
+		}
+	} else {
+		content, _, err = readSecretsFile(backup)
+		if err != nil {
+			return err
+		}
+	}
+	return writeSecretsFile(filename, content, nil)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+const backupSecrets = "[github]\nsecret = JBSWY3DPEHPK3PXP\n"
+
+func TestKeepBackup(t *testing.T) {
+	filename := writeTemp(t, "secrets.ini", backupSecrets)
+	if err := writeSecretsFile(filename, []byte("[work]\nsecret = GEZDGNBVGY3TQOJQ\n"), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTemp(t, filename+backupSuffix); got != backupSecrets {
+		t.Errorf("backup holds %q, want %q", got, backupSecrets)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	filename := writeTemp(t, "secrets.ini", backupSecrets)
+	backup := filepath.Join(t.TempDir(), "secrets.enc")
+	answerPrompts(t, "pw", "pw", "pw")
+	t.Cleanup(func() { delete(passphrases, backup) })
+	if err := backupFile(filename, backup); err != nil {
+		t.Fatal(err)
+	}
+	if content := readTemp(t, backup); !isEncrypted([]byte(content)) {
+		t.Fatal("backup is not encrypted")
+	}
+	if err := backupFile(filename, backup); err == nil {
+		t.Error("backupFile overwrote an existing backup")
+	}
+
+	if err := os.WriteFile(filename, []byte("[broken\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreFile(backup, filename); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTemp(t, filename); got != backupSecrets {
+		t.Errorf("restored %q, want %q", got, backupSecrets)
+	}
+}
+
+// TestBackupRestoreGPG runs backupFile and restoreFile against a fake
+// gpg that stores its input unencrypted.
+func TestBackupRestoreGPG(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake gpg is a shell script")
+	}
+	fake := filepath.Join(t.TempDir(), "gpg")
+	script := "#!/bin/sh\ncase \"$1\" in\n--symmetric) cat >\"$3\" ;;\n--decrypt) cat \"$2\" ;;\nesac\n"
+	if err := os.WriteFile(fake, []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	saved := gpgCommand
+	t.Cleanup(func() { gpgCommand = saved })
+	gpgCommand = fake
+
+	filename := writeTemp(t, "secrets.ini", backupSecrets)
+	backup := filepath.Join(t.TempDir(), "secrets.ini.gpg")
+	if err := backupFile(filename, backup); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTemp(t, backup); got != backupSecrets {
+		t.Errorf("gpg was given %q, want %q", got, backupSecrets)
+	}
+	if err := os.WriteFile(filename, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := restoreFile(backup, filename); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTemp(t, filename); got != backupSecrets {
+		t.Errorf("restored %q, want %q", got, backupSecrets)
+	}
+}
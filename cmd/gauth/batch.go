@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gauth"
+)
+
+// createdSecret is one of the accounts made by --create --count.
+type createdSecret struct {
+	Secret  string `json:"secret"`
+	URL     string `json:"url"`
+	Barcode string `json:"barcode,omitempty"` // PNG file of the URL's QR code
+}
+
+// numberedFile returns filename with "-n" inserted before its extension,
+// so that code.png becomes code-3.png.
+func numberedFile(filename string, n int) string {
+	ext := filepath.Ext(filename)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(filename, ext), n, ext)
+}
+
+// createSecrets generates count independent secrets for user and domain.
+// When output is set, the QR code of each account is written to the
+// numbered PNG file returned by numberedFile.
+func createSecrets(cfg gauth.Config, count, keyBits int, hotp bool, user, domain, output string) ([]createdSecret, error) {
+	created := make([]createdSecret, count)
+	for i := range created {
+		key, err := gauth.GenerateSecretKeyN(keyBits)
+		if err != nil {
+			return nil, fmt.Errorf("can not generate secret: %w", err)
+		}
+		created[i] = createdSecret{Secret: key, URL: cfg.OTPAuthURL(user, domain, key)}
+		if hotp {
+			created[i].URL = cfg.HOTPAuthURL(user, domain, key, 0)
+		}
+		if output != "" {
+			created[i].Barcode = numberedFile(output, i+1)
+			if err := writeQRPNG(expandHome(created[i].Barcode), created[i].URL); err != nil {
+				return nil, fmt.Errorf("can not write QR code: %w", err)
+			}
+		}
+	}
+	return created, nil
+}
+
+// printSecrets prints accounts made by createSecrets as a JSON array, or
+// one per line with tab separated fields. --output-uri and
+// --output-secret reduce the lines to that field.
+func printSecrets(created []createdSecret, format string, outputURI, outputSecret bool) error {
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(created)
+	}
+	for _, c := range created {
+		switch {
+		case outputURI:
+			fmt.Println(c.URL)
+		case outputSecret:
+			fmt.Println(c.Secret)
+		case c.Barcode != "":
+			fmt.Printf("%s\t%s\t%s\n", c.Secret, c.URL, c.Barcode)
+		default:
+			fmt.Printf("%s\t%s\n", c.Secret, c.URL)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"gauth"
+)
+
+// benchmarkResult summarizes the timings of one algorithm.
+type benchmarkResult struct {
+	Algorithm gauth.Algorithm
+	PerSecond float64
+	Mean      time.Duration
+	P99       time.Duration
+}
+
+// benchmark times iterations calls of GenerateCode with each algorithm.
+func benchmark(secret string, iterations int) ([]benchmarkResult, error) {
+	var results []benchmarkResult
+	durations := make([]time.Duration, iterations)
+	for _, algorithm := range []gauth.Algorithm{gauth.SHA1, gauth.SHA256, gauth.SHA512} {
+		cfg := gauth.Config{Algorithm: algorithm}
+		var total time.Duration
+		for i := range durations {
+			start := time.Now()
+			if _, err := cfg.GenerateCode(secret, uint64(i)); err != nil {
+				return nil, err
+			}
+			durations[i] = time.Since(start)
+			total += durations[i]
+		}
+		slices.Sort(durations)
+		results = append(results, benchmarkResult{
+			Algorithm: algorithm,
+			PerSecond: float64(iterations) / total.Seconds(),
+			Mean:      total / time.Duration(iterations),
+			P99:       durations[(iterations*99-1)/100],
+		})
+	}
+	return results, nil
+}
+
+// benchmarkTable returns the rows printed by --benchmark.
+func benchmarkTable(results []benchmarkResult) [][]string {
+	rows := [][]string{{"Algorithm", "Codes/s", "Mean", "P99"}}
+	for _, r := range results {
+		rows = append(rows, []string{
+			string(r.Algorithm),
+			fmt.Sprintf("%.0f", r.PerSecond),
+			r.Mean.String(),
+			r.P99.String(),
+		})
+	}
+	return rows
+}
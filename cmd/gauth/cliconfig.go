@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// CLIConfig holds the flag defaults read from the file given with
+// --config. Flags on the command line take precedence over it.
+type CLIConfig struct {
+	Algorithm string `toml:"algorithm" yaml:"algorithm"`
+	Digits    int    `toml:"digits" yaml:"digits"`
+	Period    uint   `toml:"period" yaml:"period"`
+	Style     string `toml:"style" yaml:"style"`
+	Window    *int   `toml:"window" yaml:"window"` // nil when not set, as 0 is a valid window
+	File      string `toml:"file" yaml:"file"`
+}
+
+// cliConfig is the --config of this run.
+var cliConfig CLIConfig
+
+// loadCLIConfig reads a config file in TOML, or in YAML when the file
+// has a .yaml or .yml extension or starts with "---".
+func loadCLIConfig(filename string) (CLIConfig, error) {
+	var config CLIConfig
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return config, err
+	}
+	if configFormat(filename, content) == "yaml" {
+		dec := yaml.NewDecoder(bytes.NewReader(content))
+		dec.KnownFields(true)
+		err = dec.Decode(&config)
+	} else {
+		var md toml.MetaData
+		md, err = toml.Decode(string(content), &config)
+		if undecoded := md.Undecoded(); err == nil && len(undecoded) > 0 {
+			err = fmt.Errorf("unknown key %q", undecoded[0].String())
+		}
+	}
+	if err != nil {
+		return config, fmt.Errorf("can not parse %s: %w", filename, err)
+	}
+	return config, nil
+}
+
+// flagDefaults returns the settings of config that replace the defaults
+// of the flags with the same name. The style is applied by tableStyle.
+func (config CLIConfig) flagDefaults() map[string]string {
+	defaults := make(map[string]string)
+	if config.Algorithm != "" {
+		defaults["algorithm"] = config.Algorithm
+	}
+	if config.Digits != 0 {
+		defaults["digits"] = strconv.Itoa(config.Digits)
+	}
+	if config.Period != 0 {
+		defaults["period"] = strconv.FormatUint(uint64(config.Period), 10)
+	}
+	if config.Window != nil {
+		defaults["window"] = strconv.Itoa(*config.Window)
+	}
+	if config.File != "" {
+		defaults["file"] = expandHome(config.File)
+	}
+	return defaults
+}
+
+// tableStyle returns the default --style: GOOGAUTH_STYLE, or else the
+// style of the --config file, or else the grid.
+func tableStyle() string {
+	if env, ok := os.LookupEnv("GOOGAUTH_STYLE"); ok {
+		return env
+	}
+	if cliConfig.Style != "" {
+		return cliConfig.Style
+	}
+	return "2"
+}
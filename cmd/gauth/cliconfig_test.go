@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+
+	"gauth"
+)
+
+func TestLoadCLIConfig(t *testing.T) {
+	window := 2
+	want := CLIConfig{Algorithm: "SHA256", Digits: 8, Period: 60, Style: "3", Window: &window, File: "~/secrets.ini"}
+	files := map[string]string{
+		"config.toml": "algorithm = \"SHA256\"\ndigits = 8\nperiod = 60\nstyle = \"3\"\nwindow = 2\nfile = \"~/secrets.ini\"\n",
+		"config.yaml": "algorithm: SHA256\ndigits: 8\nperiod: 60\nstyle: \"3\"\nwindow: 2\nfile: ~/secrets.ini\n",
+		"config":      "---\nalgorithm: SHA256\ndigits: 8\nperiod: 60\nstyle: \"3\"\nwindow: 2\nfile: ~/secrets.ini\n",
+	}
+	for name, content := range files {
+		config, err := loadCLIConfig(writeTemp(t, name, content))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(config, want) {
+			t.Errorf("%s: got %+v, want %+v", name, config, want)
+		}
+	}
+
+	for name, content := range map[string]string{"config.toml": "digit = 8\n", "config.yml": "digit: 8\n"} {
+		if _, err := loadCLIConfig(writeTemp(t, name, content)); err == nil {
+			t.Errorf("%s with an unknown key loaded", name)
+		}
+	}
+}
+
+func TestCLIConfigDefaults(t *testing.T) {
+	saved := cliConfig
+	t.Cleanup(func() { cliConfig = saved })
+	window := 0
+	cliConfig = CLIConfig{Algorithm: "sha512", Digits: 8, Period: 60, Window: &window}
+
+	tests := []struct {
+		args  []string
+		want  gauth.Config
+		given []string // the flags fs.Visit reports
+	}{
+		{nil, gauth.Config{Algorithm: gauth.SHA512, Digits: 8, Period: 60, Window: 0}, nil},
+		{[]string{"--digits", "6", "--window", "3"}, gauth.Config{Algorithm: gauth.SHA512, Digits: 6, Period: 60, Window: 3}, []string{"digits", "window"}},
+		{[]string{"--algorithm", "SHA1", "--period=30"}, gauth.Config{Algorithm: gauth.SHA1, Digits: 8, Period: 30, Window: 0}, []string{"algorithm", "period"}},
+	}
+	for _, test := range tests {
+		cfg := gauth.DefaultConfig
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		addConfigFlags(fs, &cfg)
+		fs.IntVar(&cfg.Window, "window", cfg.Window, "")
+		if _, err := parseArgs(fs, test.args); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(cfg, test.want) {
+			t.Errorf("%q: got %+v, want %+v", test.args, cfg, test.want)
+		}
+		var given []string
+		fs.Visit(func(f *flag.Flag) { given = append(given, f.Name) })
+		if !slices.Equal(given, test.given) {
+			t.Errorf("%q: flags %q given, want %q", test.args, given, test.given)
+		}
+	}
+
+	cliConfig = CLIConfig{Algorithm: "MD5"}
+	cfg := gauth.DefaultConfig
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	addConfigFlags(fs, &cfg)
+	if _, err := parseArgs(fs, nil); err == nil {
+		t.Error("invalid algorithm in the config file accepted")
+	}
+}
+
+func TestTableStyle(t *testing.T) {
+	saved := cliConfig
+	t.Cleanup(func() { cliConfig = saved })
+	cliConfig = CLIConfig{}
+	t.Setenv("GOOGAUTH_STYLE", "")
+	os.Unsetenv("GOOGAUTH_STYLE")
+	if got := tableStyle(); got != "2" {
+		t.Errorf("default style %q, want 2", got)
+	}
+	cliConfig.Style = "3"
+	if got := tableStyle(); got != "3" {
+		t.Errorf("style of the config file %q, want 3", got)
+	}
+	t.Setenv("GOOGAUTH_STYLE", "1")
+	if got := tableStyle(); got != "1" {
+		t.Errorf("style of GOOGAUTH_STYLE %q, want 1", got)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	colorReset  = "\x1b[0m"
+	colorDim    = "\x1b[2m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+)
+
+// isTerminal reports whether the file descriptor is a terminal.
+var isTerminal = term.IsTerminal
+
+// colorEnabled reports whether output to stdout should be colored:
+// only on terminals, and unless GOOGAUTH_COLOR=0 opts out.
+func colorEnabled() bool {
+	if os.Getenv("GOOGAUTH_COLOR") == "0" {
+		return false
+	}
+	return isTerminal(int(os.Stdout.Fd()))
+}
+
+func colorize(text, color string) string {
+	return color + text + colorReset
+}
+
+// lifeColor returns the color signalling how long a code of an account
+// with the given period remains valid: red for the last sixth of the
+// period, yellow for the third before, 5 and 10 seconds of 30.
+func lifeColor(seconds int64, period uint) string {
+	switch {
+	case seconds <= int64(period)/6:
+		return colorRed
+	case seconds <= int64(period)/3:
+		return colorYellow
+	}
+	return colorGreen
+}
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// displayWidth returns the number of terminal cells text occupies,
+// ignoring ANSI color sequences.
+func displayWidth(text string) int {
+	return utf8.RuneCountInString(ansiEscape.ReplaceAllString(text, ""))
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestColorEnabled(t *testing.T) {
+	saved := isTerminal
+	t.Cleanup(func() { isTerminal = saved })
+	tests := []struct {
+		terminal bool
+		env      string
+		want     bool
+	}{
+		{true, "", true},
+		{true, "1", true},
+		{true, "0", false},
+		{false, "", false},
+		{false, "1", false},
+	}
+	for _, test := range tests {
+		isTerminal = func(int) bool { return test.terminal }
+		t.Setenv("GOOGAUTH_COLOR", test.env)
+		if got := colorEnabled(); got != test.want {
+			t.Errorf("terminal %v, GOOGAUTH_COLOR=%q: colorEnabled() = %v, want %v", test.terminal, test.env, got, test.want)
+		}
+	}
+}
+
+func TestLifeColor(t *testing.T) {
+	tests := []struct {
+		seconds int64
+		period  uint
+		want    string
+	}{
+		{30, 30, colorGreen},
+		{11, 30, colorGreen},
+		{10, 30, colorYellow},
+		{6, 30, colorYellow},
+		{5, 30, colorRed},
+		{1, 30, colorRed},
+	}
+	for _, test := range tests {
+		if got := lifeColor(test.seconds, test.period); got != test.want {
+			t.Errorf("lifeColor(%d, %d) = %q, want %q", test.seconds, test.period, got, test.want)
+		}
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	if got := displayWidth(colorize("123456", colorRed)); got != 6 {
+		t.Errorf("displayWidth of a colored code = %d, want 6", got)
+	}
+	if got := displayWidth("…é"); got != 2 {
+		t.Errorf("displayWidth(…é) = %d, want 2", got)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// accountChange is a difference between two secrets files found by
+// compareAccounts.
+type accountChange struct {
+	Op     byte // '+' added, '-' removed or '~' changed
+	Name   string
+	Fields []string // for '~', the keys that differ
+}
+
+// String formats the change as printed by --diff. Changed secrets are
+// only named, never shown.
+func (change accountChange) String() string {
+	if change.Op == '~' {
+		return fmt.Sprintf("~ %s: %s", change.Name, strings.Join(change.Fields, ", "))
+	}
+	return fmt.Sprintf("%c %s", change.Op, change.Name)
+}
+
+// compareAccounts lists the accounts of b missing from a, of a missing
+// from b and of both that differ, sorted by name. Secrets are compared
+// ignoring case and spaces.
+func compareAccounts(a, b map[string]fileAccount) []accountChange {
+	var changes []accountChange
+	for name, old := range a {
+		acct, ok := b[name]
+		if !ok {
+			changes = append(changes, accountChange{Op: '-', Name: name})
+			continue
+		}
+		if fields := changedFields(old, acct); len(fields) > 0 {
+			changes = append(changes, accountChange{Op: '~', Name: name, Fields: fields})
+		}
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			changes = append(changes, accountChange{Op: '+', Name: name})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+// changedFields returns the INI keys whose values differ between a and
+// b: every key that affects the codes, and the user and domain.
+func changedFields(a, b fileAccount) []string {
+	normalize := func(secret string) string {
+		return strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	}
+	var fields []string
+	if normalize(a.Secret) != normalize(b.Secret) {
+		fields = append(fields, "secret")
+	}
+	if a.User != b.User {
+		fields = append(fields, "user")
+	}
+	if a.Domain != b.Domain {
+		fields = append(fields, "domain")
+	}
+	if !strings.EqualFold(a.Algorithm, b.Algorithm) {
+		fields = append(fields, "algorithm")
+	}
+	if a.Digits != b.Digits {
+		fields = append(fields, "digits")
+	}
+	if a.Period != b.Period {
+		fields = append(fields, "period")
+	}
+	if !strings.EqualFold(a.Type, b.Type) {
+		fields = append(fields, "type")
+	}
+	if a.Counter != b.Counter {
+		fields = append(fields, "counter")
+	}
+	if !strings.EqualFold(a.Format, b.Format) {
+		fields = append(fields, "format")
+	}
+	if a.PINPrefix != b.PINPrefix {
+		fields = append(fields, pinPrefixKey)
+	}
+	if a.PINSuffix != b.PINSuffix {
+		fields = append(fields, pinSuffixKey)
+	}
+	return fields
+}
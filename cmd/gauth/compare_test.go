@@ -0,0 +1,56 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestChangedFields(t *testing.T) {
+	base := fileAccount{Secret: "JBSWY3DPEHPK3PXP", User: "alice", Domain: "example.com"}
+	tests := []struct {
+		change func(*fileAccount)
+		want   []string
+	}{
+		{func(a *fileAccount) { a.Secret = "jbsw y3dp ehpk 3pxp" }, nil},
+		{func(a *fileAccount) { a.Secret = "GEZDGNBVGY3TQOJQ" }, []string{"secret"}},
+		{func(a *fileAccount) { a.User = "bob" }, []string{"user"}},
+		{func(a *fileAccount) { a.Domain = "example.org" }, []string{"domain"}},
+		{func(a *fileAccount) { a.Algorithm = "SHA256" }, []string{"algorithm"}},
+		{func(a *fileAccount) { a.Digits = 8 }, []string{"digits"}},
+		{func(a *fileAccount) { a.Period = 60 }, []string{"period"}},
+		{func(a *fileAccount) { a.Type = "hotp" }, []string{"type"}},
+		{func(a *fileAccount) { a.Counter = 3 }, []string{"counter"}},
+		{func(a *fileAccount) { a.Format = "steam" }, []string{"format"}},
+		{func(a *fileAccount) { a.PINPrefix = "12" }, []string{pinPrefixKey}},
+		{func(a *fileAccount) { a.PINSuffix = "34" }, []string{pinSuffixKey}},
+		{func(a *fileAccount) { a.Digits, a.Format = 8, "blizzard" }, []string{"digits", "format"}},
+	}
+	for _, test := range tests {
+		changed := base
+		test.change(&changed)
+		if got := changedFields(base, changed); !slices.Equal(got, test.want) {
+			t.Errorf("changing %+v: %q, want %q", changed, got, test.want)
+		}
+	}
+}
+
+func TestCompareAccounts(t *testing.T) {
+	a := map[string]fileAccount{
+		"kept":    {Secret: "JBSWY3DPEHPK3PXP"},
+		"removed": {Secret: "JBSWY3DPEHPK3PXP"},
+		"steam":   {Secret: "JBSWY3DPEHPK3PXP"},
+	}
+	b := map[string]fileAccount{
+		"kept":  {Secret: "JBSWY3DPEHPK3PXP"},
+		"added": {Secret: "JBSWY3DPEHPK3PXP"},
+		"steam": {Secret: "JBSWY3DPEHPK3PXP", Format: "steam"},
+	}
+	var got []string
+	for _, change := range compareAccounts(a, b) {
+		got = append(got, change.String())
+	}
+	want := []string{"+ added", "- removed", "~ steam: format"}
+	if !slices.Equal(got, want) {
+		t.Errorf("compareAccounts = %q, want %q", got, want)
+	}
+}
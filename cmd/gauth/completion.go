@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// completionCommand is an operation as offered by shell completion.
+type completionCommand struct {
+	Names       []string
+	Description string
+	Flags       []string // without the leading dashes
+	Args        string   // "file", "account" or "" for what follows
+	Words       []string // fixed words completed as arguments
+}
+
+var configFlagNames = []string{"algorithm", "digits", "period", "steam", "blizzard"}
+
+// globalFlagNames are accepted by every operation, see cutGlobalFlags.
+var globalFlagNames = []string{"log-level", "log-format", "config"}
+
+// completionCommands mirrors the operations of main and the flags each
+// of them registers.
+var completionCommands = []completionCommand{
+	{Names: []string{"-c", "--create"}, Description: "create a new secret",
+		Flags: slices.Concat(configFlagNames, []string{"qr", "qr-output", "output", "hotp", "key-bits", "issuer", "recovery-codes", "save", "dry-run", "output-uri", "output-secret", "output-barcode", "count", "format"})},
+	{Names: []string{"-v", "--verify"}, Description: "verify a time-based code",
+		Flags: slices.Concat(configFlagNames, []string{"clock-drift", "window", "strict", "quiet", "audit-log", "state-file", "label", "pin-prefix", "pin-suffix", "interactive", "time", "secret-env", "secret-file"})},
+	{Names: []string{"-d", "--display"}, Description: "display the current code",
+		Flags: slices.Concat(configFlagNames, []string{"qr", "copy", "clear-on-expire", "watch", "quiet", "pin-prefix", "pin-suffix", "interactive", "time", "secret-env", "secret-file"})},
+	{Names: []string{"--hotp"}, Description: "display a counter-based code",
+		Flags: configFlagNames},
+	{Names: []string{"--hotp-verify"}, Description: "verify a counter-based code",
+		Flags: slices.Concat(configFlagNames, []string{"window"})},
+	{Names: []string{"-l", "--list"}, Description: "list the codes of a secrets file", Args: "file",
+		Flags: slices.Concat(configFlagNames, []string{"continue", "once", "interval", "align-refresh", "watch-file", "no-tui", "interactive", "prev", "next", "format", "timestamp", "template", "style", "sort", "search", "copy", "clear-on-expire", "keychain", "config-dir"})},
+	{Names: []string{"-a", "--add"}, Description: "add an account to a secrets file", Args: "file",
+		Flags: []string{"user", "domain", "profile", "secret", "qr", "qr-output", "output", "keychain", "dry-run"}},
+	{Names: []string{"-r", "--remove"}, Description: "remove an account from a secrets file", Args: "file",
+		Flags: []string{"keychain", "dry-run"}},
+	{Names: []string{"--rename"}, Description: "rename an account of a secrets file", Args: "file",
+		Flags: []string{"dry-run"}},
+	{Names: []string{"--copy-entry"}, Description: "duplicate an account of a secrets file", Args: "file",
+		Flags: []string{"force", "dry-run"}},
+	{Names: []string{"--count"}, Description: "print the number of accounts", Args: "file"},
+	{Names: []string{"--check-expiry"}, Description: "check HOTP counters for overflow and staleness", Args: "file"},
+	{Names: []string{"--list-recovery"}, Description: "print stored recovery codes", Args: "file"},
+	{Names: []string{"--diff"}, Description: "compare the accounts of two secrets files", Args: "file"},
+	{Names: []string{"--merge"}, Description: "merge two secrets files", Args: "file",
+		Flags: []string{"output", "on-conflict", "dry-run"}},
+	{Names: []string{"--print-audit"}, Description: "print a verification audit log", Args: "file",
+		Flags: []string{"style"}},
+	{Names: []string{"--validate-secret"}, Description: "check a base32 secret"},
+	{Names: []string{"--export"}, Description: "export accounts as an otpauth-migration URL", Args: "file",
+		Flags: slices.Concat(configFlagNames, []string{"qr"})},
+	{Names: []string{"--import"}, Description: "import an otpauth-migration URL", Args: "file",
+		Flags: []string{"url", "dry-run"}},
+	{Names: []string{"--save-toml"}, Description: "save a secrets file as TOML", Args: "file"},
+	{Names: []string{"--save-yaml"}, Description: "save a secrets file as YAML", Args: "file"},
+	{Names: []string{"--backup"}, Description: "write an encrypted backup", Args: "file",
+		Flags: []string{"dry-run"}},
+	{Names: []string{"--restore"}, Description: "restore an encrypted backup", Args: "file",
+		Flags: []string{"dry-run"}},
+	{Names: []string{"--daemon"}, Description: "serve codes on a Unix socket", Args: "file",
+		Flags: slices.Concat(configFlagNames, []string{"socket", "file"})},
+	{Names: []string{"--client"}, Description: "ask the daemon for a code", Args: "account",
+		Flags: []string{"socket"}},
+	{Names: []string{"--serve"}, Description: "serve codes over HTTP",
+		Flags: slices.Concat(configFlagNames, []string{"addr", "file", "token", "tls-cert", "tls-key", "request-timeout", "metrics", "scim", "state-file"})},
+	{Names: []string{"--selftest"}, Description: "check the RFC 6238 test vectors"},
+	{Names: []string{"--benchmark"}, Description: "time code generation",
+		Flags: []string{"iterations", "style"}},
+	{Names: []string{"--encrypt"}, Description: "encrypt a secrets file", Args: "file"},
+	{Names: []string{"--decrypt"}, Description: "decrypt a secrets file", Args: "file"},
+	{Names: []string{"--completion"}, Description: "print a shell completion script",
+		Words: []string{"bash", "zsh", "fish"}},
+}
+
+// fileFlags take a file or directory name.
+var fileFlags = []string{"qr-output", "output", "secret-file", "file", "tls-cert", "tls-key", "socket", "config-dir", "audit-log", "state-file", "config", "save"}
+
+// accountFlags of --list take an account name.
+var accountFlags = []string{"copy", "search"}
+
+// accountsCommand lists the account names of the default secrets file,
+// one per line, for completing them.
+const accountsCommand = "gauth --list --format csv </dev/null 2>/dev/null | tail -n +2 | cut -d, -f1"
+
+// completionScript returns the completion script for shell.
+func completionScript(shell string) (string, error) {
+	switch shell {
+	case "bash":
+		return bashCompletion(), nil
+	case "zsh":
+		return zshCompletion(), nil
+	case "fish":
+		return fishCompletion(), nil
+	}
+	return "", fmt.Errorf("unknown shell %q: use bash, zsh or fish", shell)
+}
+
+func commandNames() []string {
+	var names []string
+	for _, command := range completionCommands {
+		names = append(names, command.Names...)
+	}
+	return names
+}
+
+func dashed(flags []string) []string {
+	out := make([]string, len(flags))
+	for i, flag := range flags {
+		out[i] = "--" + flag
+	}
+	return out
+}
+
+func bashCompletion() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "_gauth_accounts() {\n\t%s\n}\n\n", accountsCommand)
+	b.WriteString("_gauth() {\n")
+	b.WriteString("\tlocal cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}\n")
+	b.WriteString("\tif [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+	fmt.Fprintf(&b, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(commandNames(), " "))
+	b.WriteString("\t\treturn\n\tfi\n")
+	b.WriteString("\tcase $prev in\n")
+	fmt.Fprintf(&b, "\t%s)\n\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n\t\treturn\n\t\t;;\n", strings.Join(dashed(fileFlags), "|"))
+	b.WriteString("\tesac\n")
+	b.WriteString("\tlocal flags= args= words=\n")
+	b.WriteString("\tcase ${COMP_WORDS[1]} in\n")
+	for _, command := range completionCommands {
+		fmt.Fprintf(&b, "\t%s)\n", strings.Join(command.Names, "|"))
+		fmt.Fprintf(&b, "\t\tflags=%q args=%q words=%q\n", strings.Join(dashed(slices.Concat(command.Flags, globalFlagNames)), " "), command.Args, strings.Join(command.Words, " "))
+		if command.Names[0] == "-l" {
+			fmt.Fprintf(&b, "\t\tcase $prev in\n\t\t%s)\n", strings.Join(dashed(accountFlags), "|"))
+			b.WriteString("\t\t\tCOMPREPLY=($(compgen -W \"$(_gauth_accounts)\" -- \"$cur\"))\n\t\t\treturn\n\t\t\t;;\n\t\tesac\n")
+		}
+		b.WriteString("\t\t;;\n")
+	}
+	b.WriteString("\tesac\n")
+	b.WriteString("\tif [[ $cur == -* ]]; then\n")
+	b.WriteString("\t\tCOMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n")
+	b.WriteString("\telif [ \"$args\" = file ]; then\n")
+	b.WriteString("\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	b.WriteString("\telif [ \"$args\" = account ]; then\n")
+	b.WriteString("\t\tCOMPREPLY=($(compgen -W \"$(_gauth_accounts)\" -- \"$cur\"))\n")
+	b.WriteString("\telse\n")
+	b.WriteString("\t\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	b.WriteString("\tfi\n}\n\n")
+	b.WriteString("complete -o filenames -F _gauth gauth\n")
+	return b.String()
+}
+
+func zshCompletion() string {
+	var b strings.Builder
+	b.WriteString("#compdef gauth\n\n")
+	fmt.Fprintf(&b, "_gauth_accounts() {\n\tcompadd -- ${(f)\"$(%s)\"}\n}\n\n", accountsCommand)
+	b.WriteString("_gauth() {\n")
+	b.WriteString("\tif (( CURRENT == 2 )); then\n")
+	fmt.Fprintf(&b, "\t\tcompadd -- %s\n", strings.Join(commandNames(), " "))
+	b.WriteString("\t\treturn\n\tfi\n")
+	b.WriteString("\tcase ${words[CURRENT-1]} in\n")
+	fmt.Fprintf(&b, "\t%s)\n\t\t_files\n\t\treturn\n\t\t;;\n", strings.Join(dashed(fileFlags), "|"))
+	b.WriteString("\tesac\n")
+	b.WriteString("\tlocal -a flags words_\n\tlocal args\n")
+	b.WriteString("\tcase ${words[2]} in\n")
+	for _, command := range completionCommands {
+		fmt.Fprintf(&b, "\t%s)\n", strings.Join(command.Names, "|"))
+		fmt.Fprintf(&b, "\t\tflags=(%s) args=%q words_=(%s)\n", strings.Join(dashed(slices.Concat(command.Flags, globalFlagNames)), " "), command.Args, strings.Join(command.Words, " "))
+		if command.Names[0] == "-l" {
+			fmt.Fprintf(&b, "\t\tcase ${words[CURRENT-1]} in\n\t\t%s)\n", strings.Join(dashed(accountFlags), "|"))
+			b.WriteString("\t\t\t_gauth_accounts\n\t\t\treturn\n\t\t\t;;\n\t\tesac\n")
+		}
+		b.WriteString("\t\t;;\n")
+	}
+	b.WriteString("\tesac\n")
+	b.WriteString("\tif [[ ${words[CURRENT]} == -* ]]; then\n")
+	b.WriteString("\t\tcompadd -- $flags\n")
+	b.WriteString("\telif [[ $args == file ]]; then\n")
+	b.WriteString("\t\t_files\n")
+	b.WriteString("\telif [[ $args == account ]]; then\n")
+	b.WriteString("\t\t_gauth_accounts\n")
+	b.WriteString("\telse\n")
+	b.WriteString("\t\tcompadd -- $words_\n")
+	b.WriteString("\tfi\n}\n\n")
+	b.WriteString("if [ \"$funcstack[1]\" = \"_gauth\" ]; then\n\t_gauth \"$@\"\nelse\n\tcompdef _gauth gauth\nfi\n")
+	return b.String()
+}
+
+func fishCompletion() string {
+	var b strings.Builder
+	b.WriteString("function __gauth_needs_command\n\ttest (count (commandline -opc)) -eq 1\nend\n\n")
+	b.WriteString("function __gauth_using\n\tset -l tokens (commandline -opc)\n\ttest (count $tokens) -ge 2; and contains -- $tokens[2] $argv\nend\n\n")
+	fmt.Fprintf(&b, "function __gauth_accounts\n\t%s\nend\n\n", accountsCommand)
+	b.WriteString("complete -c gauth -f\n")
+	for _, command := range completionCommands {
+		for _, name := range command.Names {
+			fmt.Fprintf(&b, "complete -c gauth -n __gauth_needs_command -a '%s' -d %q\n", name, command.Description)
+		}
+	}
+	for _, command := range completionCommands {
+		using := fmt.Sprintf("'__gauth_using %s'", strings.Join(command.Names, " "))
+		for _, flag := range slices.Concat(command.Flags, globalFlagNames) {
+			option := "-l " + flag
+			switch {
+			case slices.Contains(fileFlags, flag):
+				option += " -r -F"
+			case command.Names[0] == "-l" && slices.Contains(accountFlags, flag):
+				option += " -x -a '(__gauth_accounts)'"
+			}
+			fmt.Fprintf(&b, "complete -c gauth -n %s %s\n", using, option)
+		}
+		switch {
+		case command.Args == "file":
+			fmt.Fprintf(&b, "complete -c gauth -n %s -F\n", using)
+		case command.Args == "account":
+			fmt.Fprintf(&b, "complete -c gauth -n %s -a '(__gauth_accounts)'\n", using)
+		case len(command.Words) > 0:
+			fmt.Fprintf(&b, "complete -c gauth -n %s -a %q\n", using, strings.Join(command.Words, " "))
+		}
+	}
+	return b.String()
+}
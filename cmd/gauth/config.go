@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gauth"
+)
+
+// configFormat returns the format of a secrets file: "toml" for .toml
+// files, "yaml" for .yaml and .yml files or content starting with a
+// "---" document marker, and "ini" for anything else.
+func configFormat(filename string, content []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".toml":
+		return "toml"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("---")) {
+		return "yaml"
+	}
+	return "ini"
+}
+
+// loadConfig reads a secrets file in the format found by configFormat,
+// decrypting it first when it was encrypted with --encrypt. Every format
+// is returned as INI sections keyed by account name, together with the
+// section names in the order they appear in the file. The recovery codes
+// section of INI files is left out.
+func loadConfig(filename string) (map[string]map[string]string, []string, error) {
+	content, _, err := readSecretsFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	var config map[string]map[string]string
+	var order []string
+	switch configFormat(filename, content) {
+	case "toml":
+		config, order, err = parseTOML(content)
+	case "yaml":
+		config, order, err = parseYAML(content)
+	default:
+		doc := parseINIDocument(string(content))
+		config, order = doc.config(), doc.names()
+		delete(config, recoverySection)
+		order = slices.DeleteFunc(order, func(name string) bool { return name == recoverySection })
+		return config, order, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("can not parse %s: %w", filename, err)
+	}
+	return config, order, nil
+}
+
+// encodeConfig returns the text of the INI sections in config in the
+// given format.
+func encodeConfig(format string, config map[string]map[string]string) ([]byte, error) {
+	accounts, err := fileAccounts(config)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "toml":
+		return encodeTOML(accounts)
+	case "yaml":
+		return encodeYAML(accounts)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// checkWritable reports an error for secrets files that the commands
+// editing them in place do not support. A missing file is writable.
+func checkWritable(filename string) error {
+	content, _, err := readSecretsFile(filename)
+	if err != nil && !errors.Is(err, gauth.ErrFileNotFound) {
+		return err
+	}
+	if format := configFormat(filename, content); format != "ini" {
+		return fmt.Errorf("can not modify %s: %s files are read only, edit them by hand", filename, strings.ToUpper(format))
+	}
+	return nil
+}
+
+// fileAccount is an account as stored in the structured file formats.
+// Its fields mirror the keys of an INI section.
+type fileAccount struct {
+	Secret    string `toml:"secret" yaml:"secret"`
+	User      string `toml:"user,omitempty" yaml:"user,omitempty"`
+	Domain    string `toml:"domain,omitempty" yaml:"domain,omitempty"`
+	Algorithm string `toml:"algorithm,omitempty" yaml:"algorithm,omitempty"`
+	Digits    int    `toml:"digits,omitzero" yaml:"digits,omitempty"`
+	Period    uint   `toml:"period,omitzero" yaml:"period,omitempty"`
+	Format    string `toml:"format,omitempty" yaml:"format,omitempty"`
+	Type      string `toml:"type,omitempty" yaml:"type,omitempty"`
+	Counter   uint64 `toml:"counter,omitzero" yaml:"counter,omitempty"`
+
+	CounterUpdated string `toml:"counter_updated,omitempty" yaml:"counter_updated,omitempty"`
+	PINPrefix      string `toml:"pin_prefix,omitempty" yaml:"pin_prefix,omitempty"`
+	PINSuffix      string `toml:"pin_suffix,omitempty" yaml:"pin_suffix,omitempty"`
+}
+
+// newFileAccount converts an INI section to a fileAccount.
+func newFileAccount(section map[string]string) (fileAccount, error) {
+	acct := fileAccount{
+		Secret:    section["secret"],
+		User:      section["user"],
+		Domain:    section["domain"],
+		Algorithm: section["algorithm"],
+		Format:    section["format"],
+		Type:      section["type"],
+
+		CounterUpdated: section[counterUpdatedKey],
+		PINPrefix:      section[pinPrefixKey],
+		PINSuffix:      section[pinSuffixKey],
+	}
+	if value, ok := section["digits"]; ok {
+		digits, err := strconv.Atoi(value)
+		if err != nil {
+			return acct, fmt.Errorf("invalid digits %q", value)
+		}
+		acct.Digits = digits
+	}
+	if value, ok := section["period"]; ok {
+		period, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			return acct, fmt.Errorf("invalid period %q", value)
+		}
+		acct.Period = uint(period)
+	}
+	if value, ok := section["counter"]; ok {
+		counter, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return acct, fmt.Errorf("invalid counter %q", value)
+		}
+		acct.Counter = counter
+	}
+	return acct, nil
+}
+
+// section converts acct to INI section values, leaving out unset keys.
+func (acct fileAccount) section() map[string]string {
+	section := map[string]string{"secret": acct.Secret}
+	set := func(key, value string) {
+		if value != "" {
+			section[key] = value
+		}
+	}
+	set("user", acct.User)
+	set("domain", acct.Domain)
+	set("algorithm", acct.Algorithm)
+	set("format", acct.Format)
+	set("type", acct.Type)
+	set(counterUpdatedKey, acct.CounterUpdated)
+	set(pinPrefixKey, acct.PINPrefix)
+	set(pinSuffixKey, acct.PINSuffix)
+	if acct.Digits != 0 {
+		section["digits"] = strconv.Itoa(acct.Digits)
+	}
+	if acct.Period != 0 {
+		section["period"] = strconv.FormatUint(uint64(acct.Period), 10)
+	}
+	if acct.Counter != 0 {
+		section["counter"] = strconv.FormatUint(acct.Counter, 10)
+	}
+	return section
+}
+
+// fileAccounts converts INI sections to fileAccounts keyed by name.
+func fileAccounts(config map[string]map[string]string) (map[string]fileAccount, error) {
+	accounts := make(map[string]fileAccount, len(config))
+	for name, section := range config {
+		acct, err := newFileAccount(section)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry [%s]: %v", name, err)
+		}
+		accounts[name] = acct
+	}
+	return accounts, nil
+}
+
+// sections converts fileAccounts keyed by name back to INI sections.
+func sections(accounts map[string]fileAccount) map[string]map[string]string {
+	config := make(map[string]map[string]string, len(accounts))
+	for name, acct := range accounts {
+		config[name] = acct.section()
+	}
+	return config
+}
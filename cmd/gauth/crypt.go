@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// encryptedMagic starts every secrets file written by --encrypt. It is
+// followed by the scrypt salt, the GCM nonce and the sealed INI text.
+var encryptedMagic = []byte("GAUTHENC\x01")
+
+const (
+	saltSize = 16
+	scryptN  = 1 << 15
+	scryptR  = 8
+	scryptP  = 1
+)
+
+var errWrongPassphrase = errors.New("wrong passphrase or corrupted file")
+
+func isEncrypted(content []byte) bool {
+	return bytes.HasPrefix(content, encryptedMagic)
+}
+
+func newCipher(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptContent seals plaintext with AES-256-GCM under a key derived
+// from passphrase.
+func encryptContent(plaintext, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append([]byte(nil), encryptedMagic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, encryptedMagic), nil
+}
+
+// decryptContent reverses encryptContent.
+func decryptContent(content, passphrase []byte) ([]byte, error) {
+	if !isEncrypted(content) {
+		return nil, errors.New("not an encrypted secrets file")
+	}
+	content = content[len(encryptedMagic):]
+	if len(content) < saltSize {
+		return nil, errWrongPassphrase
+	}
+	aead, err := newCipher(passphrase, content[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	content = content[saltSize:]
+	if len(content) < aead.NonceSize() {
+		return nil, errWrongPassphrase
+	}
+	nonce, sealed := content[:aead.NonceSize()], content[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, encryptedMagic)
+	if err != nil {
+		return nil, errWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+// stdin buffers standard input when it is not a terminal, so several
+// prompts can read successive lines.
+var stdin = bufio.NewReader(os.Stdin)
+
+// terminalOnly makes readSecret and readAnswer refuse to read from a
+// stdin that is not a terminal, for --list --interactive.
+var terminalOnly bool
+
+// readSecret prompts on stderr and reads a line from stdin, with echo
+// disabled when stdin is a terminal. It is a variable so the prompts
+// can be driven without a terminal.
+var readSecret = func(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, prompt)
+		secret, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(secret), err
+	}
+	if terminalOnly {
+		return "", errors.New("can not prompt, stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := stdin.ReadString('\n')
+	fmt.Fprintln(os.Stderr)
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readAnswer prompts on stderr and reads a line from stdin with echo,
+// for answers that are not secret.
+func readAnswer(prompt string) (string, error) {
+	if terminalOnly && !isTerminal(int(os.Stdin.Fd())) {
+		return "", errors.New("can not prompt, stdin is not a terminal")
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readPassphrase reads a passphrase with readSecret.
+func readPassphrase(prompt string) ([]byte, error) {
+	passphrase, err := readSecret(prompt)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(passphrase), nil
+}
+
+// passphrases remembers the passphrase of each encrypted file read so
+// far, so the user is asked only once per run.
+var passphrases = make(map[string][]byte)
+
+// readSecretsFile returns the plain text of filename, prompting for the
+// passphrase when the file is encrypted. The passphrase is returned so
+// the file can be written back with writeSecretsFile. A missing file is
+// reported as gauth.ErrFileNotFound.
+func readSecretsFile(filename string) (content, passphrase []byte, err error) {
+	content, err = readFile(filename)
+	if err != nil || !isEncrypted(content) {
+		return content, nil, err
+	}
+	if passphrase, ok := passphrases[filename]; ok {
+		if plaintext, err := decryptContent(content, passphrase); err == nil {
+			return plaintext, passphrase, nil
+		}
+	}
+	passphrase, err = readPassphrase(fmt.Sprintf("passphrase for %s: ", filename))
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err = decryptContent(content, passphrase)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	passphrases[filename] = passphrase
+	return content, passphrase, nil
+}
+
+// writeSecretsFile atomically replaces filename with content, encrypted
+// when passphrase is not nil. The previous file is kept as a backup.
+// In a dry run the changes are printed as a diff instead.
+func writeSecretsFile(filename string, content, passphrase []byte) error {
+	if dryRun {
+		return printDiff(filename, content)
+	}
+	if err := keepBackup(filename, passphrase); err != nil {
+		return err
+	}
+	if passphrase != nil {
+		var err error
+		content, err = encryptContent(content, passphrase)
+		if err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(filename, content)
+}
+
+func encryptFile(filename string) error {
+	content, err := readFile(filename)
+	if err != nil {
+		return err
+	}
+	if isEncrypted(content) {
+		return fmt.Errorf("%s is already encrypted", filename)
+	}
+	passphrase, err := readNewPassphrase()
+	if err != nil {
+		return err
+	}
+	return writeSecretsFile(filename, content, passphrase)
+}
+
+// readNewPassphrase asks for a new passphrase twice and returns it when
+// both entries match.
+func readNewPassphrase() ([]byte, error) {
+	passphrase, err := readPassphrase("new passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	confirm, err := readPassphrase("repeat passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(passphrase, confirm) {
+		return nil, errors.New("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+func decryptFile(filename string) error {
+	content, err := readFile(filename)
+	if err != nil {
+		return err
+	}
+	if !isEncrypted(content) {
+		return fmt.Errorf("%s is not encrypted", filename)
+	}
+	content, _, err = readSecretsFile(filename)
+	if err != nil {
+		return err
+	}
+	return writeSecretsFile(filename, content, nil)
+}
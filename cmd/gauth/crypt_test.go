@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// answerPrompts makes readSecret return answers in turn for the rest of
+// the test.
+func answerPrompts(t *testing.T, answers ...string) {
+	t.Helper()
+	saved := readSecret
+	t.Cleanup(func() { readSecret = saved })
+	readSecret = func(prompt string) (string, error) {
+		if len(answers) == 0 {
+			t.Fatalf("unexpected prompt %q", prompt)
+		}
+		answer := answers[0]
+		answers = answers[1:]
+		return answer, nil
+	}
+}
+
+func TestEncryptDecryptContent(t *testing.T) {
+	plaintext := []byte("[github]\nsecret = JBSWY3DPEHPK3PXP\n")
+	sealed, err := encryptContent(plaintext, []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncrypted(sealed) || bytes.Contains(sealed, []byte("JBSWY3DPEHPK3PXP")) {
+		t.Fatal("encrypted content is not sealed")
+	}
+	opened, err := decryptContent(sealed, []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Errorf("decrypted %q, want %q", opened, plaintext)
+	}
+	if _, err := decryptContent(sealed, []byte("wrong horse")); !errors.Is(err, errWrongPassphrase) {
+		t.Errorf("decrypting with the wrong passphrase returned %v, want errWrongPassphrase", err)
+	}
+	if _, err := decryptContent(sealed[:len(encryptedMagic)+4], []byte("correct horse")); !errors.Is(err, errWrongPassphrase) {
+		t.Errorf("decrypting truncated content returned %v, want errWrongPassphrase", err)
+	}
+}
+
+func TestEncryptFile(t *testing.T) {
+	const plaintext = "[github]\nsecret = JBSWY3DPEHPK3PXP\n"
+	filename := writeTemp(t, "secrets.ini", plaintext)
+	answerPrompts(t, "pw", "pw", "pw")
+	if err := encryptFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTemp(t, filename); !isEncrypted([]byte(got)) {
+		t.Fatal("file is not encrypted")
+	}
+	t.Cleanup(func() { delete(passphrases, filename) })
+	config, _, err := loadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config["github"]["secret"] != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("read %v from the encrypted file", config)
+	}
+}
+
+func TestEncryptFileBackup(t *testing.T) {
+	const plaintext = "[github]\nsecret = JBSWY3DPEHPK3PXP\n"
+	filename := writeTemp(t, "secrets.ini", plaintext)
+	if err := writeSecretsFile(filename, []byte(plaintext), nil); err != nil {
+		t.Fatal(err)
+	}
+	answerPrompts(t, "pw", "pw")
+	if err := encryptFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	backup := readTemp(t, filename+backupSuffix)
+	if !isEncrypted([]byte(backup)) || strings.Contains(backup, "JBSWY3DPEHPK3PXP") {
+		t.Fatalf("plaintext backup left next to the encrypted file:\n%q", backup)
+	}
+	content, err := decryptContent([]byte(backup), []byte("pw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != plaintext {
+		t.Errorf("backup decrypts to %q, want %q", content, plaintext)
+	}
+}
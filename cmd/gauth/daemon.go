@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// codeRequest asks the daemon for the current code of an account.
+type codeRequest struct {
+	Account string `json:"account"`
+}
+
+// codeResponse answers a codeRequest. ExpiresIn is left out for HOTP
+// accounts, whose codes do not expire.
+type codeResponse struct {
+	Code      string `json:"code,omitempty"`
+	ExpiresIn int64  `json:"expires_in,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// defaultSocket returns the socket path used when --socket is not given.
+// Without XDG_RUNTIME_DIR the socket goes into socketDir rather than
+// straight into the shared temporary directory.
+func defaultSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, fmt.Sprintf("gauth-%d.sock", os.Getuid()))
+	}
+	return filepath.Join(socketDir(), "daemon.sock")
+}
+
+// socketDir is the directory of the default socket when XDG_RUNTIME_DIR
+// is not set: one per user in the temporary directory.
+func socketDir() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("gauth-%d", os.Getuid()))
+}
+
+// privateDir creates dir accessible to its owner only, or checks that
+// an existing dir is such a directory and not a symlink, as another
+// user may have created it first.
+func privateDir(dir string) error {
+	if err := os.Mkdir(dir, 0700); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	// Windows reports no Unix permissions, but its temporary directory
+	// is already private to the user.
+	if !info.IsDir() || runtime.GOOS != "windows" && info.Mode().Perm() != 0700 {
+		return fmt.Errorf("%s is not a private directory (mode %v)", dir, info.Mode())
+	}
+	return nil
+}
+
+// accountCode returns the current code of the account called name.
+func accountCode(table []account, name string) codeResponse {
+	acct, ok := findAccount(table, name)
+	if !ok {
+		return codeResponse{Error: fmt.Sprintf("account %q not found", name)}
+	}
+	now := time.Now()
+	code, err := acct.code(now)
+	if err != nil {
+		return codeResponse{Error: err.Error()}
+	}
+	resp := codeResponse{Code: code}
+	if acct.Type == "totp" {
+		resp.ExpiresIn = int64(acct.Config.Expiry(now).Sub(now).Round(time.Second) / time.Second)
+	}
+	return resp
+}
+
+// serveSocket answers line-delimited JSON code requests on a Unix socket
+// at path until interrupted. The socket is only accessible to its owner.
+func serveSocket(path string, table []account) error {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("a daemon is already listening on %s", path)
+	}
+	if dir := filepath.Dir(path); dir == socketDir() {
+		if err := privateDir(dir); err != nil {
+			return err
+		}
+	}
+	os.Remove(path) // stale socket of a daemon that did not exit cleanly
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	if err := os.Chmod(path, 0600); err != nil {
+		return err
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go handleSocket(conn, table)
+	}
+}
+
+func handleSocket(conn net.Conn, table []account) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	enc := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req codeRequest
+		resp := codeResponse{}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = "invalid request: " + err.Error()
+		} else {
+			resp = accountCode(table, req.Account)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// queryDaemon asks the daemon listening on path for the code of account.
+func queryDaemon(path, account string) (codeResponse, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return codeResponse{}, fmt.Errorf("can not reach daemon: %w", err)
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(codeRequest{Account: account}); err != nil {
+		return codeResponse{}, err
+	}
+	var resp codeResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return codeResponse{}, err
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrivateDir(t *testing.T) {
+	base := t.TempDir()
+	dir := filepath.Join(base, "gauth-1000")
+	if err := privateDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0700 {
+		t.Fatalf("created %v, %v, want a 0700 directory", info, err)
+	}
+	if err := privateDir(dir); err != nil {
+		t.Errorf("reusing the directory: %v", err)
+	}
+
+	shared := filepath.Join(base, "shared")
+	if err := os.Mkdir(shared, 0777); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(shared, 0777)
+	if err := privateDir(shared); err == nil {
+		t.Error("accepted a directory other users can write to")
+	}
+	link := filepath.Join(base, "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := privateDir(link); err == nil {
+		t.Error("accepted a symlink")
+	}
+}
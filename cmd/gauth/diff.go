@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"gauth"
+)
+
+// dryRun makes writeSecretsFile print the changes it would make as a
+// unified diff instead of writing them. dryRunChanged records whether
+// any change was found.
+var (
+	dryRun        bool
+	dryRunChanged bool
+)
+
+// exitDryRun ends a dry run, exiting with status 2 when something would
+// have been changed and 0 otherwise.
+func exitDryRun() {
+	if dryRunChanged {
+		os.Exit(2)
+	}
+	os.Exit(0)
+}
+
+// printDiff prints the difference between the plain text of filename
+// and content, which would replace it.
+func printDiff(filename string, content []byte) error {
+	current, _, err := readSecretsFile(filename)
+	oldName := filename
+	if errors.Is(err, gauth.ErrFileNotFound) {
+		current, oldName, err = nil, "/dev/null", nil
+	}
+	if err != nil {
+		return err
+	}
+	diff := unifiedDiff(oldName, filename, string(current), string(content))
+	if diff != "" {
+		fmt.Print(diff)
+		dryRunChanged = true
+	}
+	return nil
+}
+
+// diffContext is the number of unchanged lines shown around changes.
+const diffContext = 3
+
+type diffLine struct {
+	Op   byte // ' ', '-' or '+'
+	Text string
+}
+
+// diffLines returns the edit script turning a into b, computed from
+// their longest common subsequence. Secrets files are small, so the
+// quadratic table is fine.
+func diffLines(a, b []string) []diffLine {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var script []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			script = append(script, diffLine{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			script = append(script, diffLine{'-', a[i]})
+			i++
+		default:
+			script = append(script, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	return script
+}
+
+// unifiedDiff returns the changes from oldText to newText in unified
+// diff format, or "" when they are equal.
+func unifiedDiff(oldName, newName, oldText, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+	script := diffLines(splitLines(oldText), splitLines(newText))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	oldLine, newLine := 0, 0 // lines consumed before script[pos]
+	for pos := 0; pos < len(script); {
+		if script[pos].Op == ' ' {
+			oldLine++
+			newLine++
+			pos++
+			continue
+		}
+		// Extend the hunk over changes separated by little context.
+		start := max(pos-diffContext, 0)
+		end := pos
+		for k := pos; k < len(script) && k-end <= 2*diffContext; k++ {
+			if script[k].Op != ' ' {
+				end = k + 1
+			}
+		}
+		end = min(end+diffContext, len(script))
+
+		oldStart, newStart := oldLine-(pos-start), newLine-(pos-start)
+		var oldCount, newCount int
+		for _, line := range script[start:end] {
+			if line.Op != '+' {
+				oldCount++
+			}
+			if line.Op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%s +%s @@\n", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))
+		for _, line := range script[start:end] {
+			b.WriteByte(line.Op)
+			b.WriteString(line.Text)
+			if !strings.HasSuffix(line.Text, "\n") {
+				b.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		for _, line := range script[pos:end] {
+			if line.Op != '+' {
+				oldLine++
+			}
+			if line.Op != '-' {
+				newLine++
+			}
+		}
+		pos = end
+	}
+	return b.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// counterUpdatedKey records in a HOTP section when its counter was last
+// advanced, as an RFC 3339 time.
+const counterUpdatedKey = "counter_updated"
+
+const (
+	// counterLimit is the HOTP counter from which --check-expiry warns
+	// about the counter running out.
+	counterLimit = 1<<63 - 1000
+	// staleCounterAge is how long a HOTP counter may stay unchanged
+	// before --check-expiry reports it as stale.
+	staleCounterAge = 90 * 24 * time.Hour
+)
+
+// checkCounters returns a warning for every HOTP section of config whose
+// counter approaches counterLimit or was last advanced more than
+// staleCounterAge before now. Sections not recording when their counter
+// was advanced are never stale.
+func checkCounters(config map[string]map[string]string, now time.Time) []string {
+	names := make([]string, 0, len(config))
+	for name := range config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var warnings []string
+	for _, name := range names {
+		section := config[name]
+		if !strings.EqualFold(section["type"], "hotp") {
+			continue
+		}
+		if value, ok := section["counter"]; ok {
+			counter, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("[%s]: invalid counter %q", name, value))
+				continue
+			}
+			if counter > counterLimit {
+				warnings = append(warnings, fmt.Sprintf("[%s]: counter %d is close to overflowing", name, counter))
+			}
+		}
+		if value, ok := section[counterUpdatedKey]; ok {
+			updated, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("[%s]: invalid %s %q", name, counterUpdatedKey, value))
+				continue
+			}
+			if age := now.Sub(updated); age > staleCounterAge {
+				warnings = append(warnings, fmt.Sprintf("[%s]: counter not advanced for %d days", name, int(age.Hours()/24)))
+			}
+		}
+	}
+	return warnings
+}
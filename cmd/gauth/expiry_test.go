@@ -0,0 +1,31 @@
+package main
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCheckCounters(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	config := map[string]map[string]string{
+		"fresh":     {"type": "hotp", "counter": "5", counterUpdatedKey: now.Add(-24 * time.Hour).Format(time.RFC3339)},
+		"stale":     {"type": "HOTP", "counter": "5", counterUpdatedKey: now.Add(-100 * 24 * time.Hour).Format(time.RFC3339)},
+		"limit":     {"type": "hotp", "counter": strconv.FormatUint(counterLimit, 10)},
+		"overflow":  {"type": "hotp", "counter": strconv.FormatUint(counterLimit+1, 10)},
+		"bad":       {"type": "hotp", "counter": "-1"},
+		"badtime":   {"type": "hotp", counterUpdatedKey: "yesterday"},
+		"untracked": {"type": "hotp"},
+		"totp":      {"counter": strconv.FormatUint(counterLimit+1, 10), counterUpdatedKey: "2000-01-01T00:00:00Z"},
+	}
+	want := []string{
+		`[bad]: invalid counter "-1"`,
+		`[badtime]: invalid counter_updated "yesterday"`,
+		"[overflow]: counter 9223372036854774809 is close to overflowing",
+		"[stale]: counter not advanced for 100 days",
+	}
+	if got := checkCounters(config, now); !slices.Equal(got, want) {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
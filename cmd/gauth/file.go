@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"gauth"
+)
+
+// expandHome replaces the "~" of "~" or a "~/" prefix in filename
+// with the home directory. Other tildes are left alone.
+func expandHome(filename string) string {
+	if filename != "~" && !strings.HasPrefix(filename, "~/") {
+		return filename
+	}
+	homeDir, _ := os.UserHomeDir()
+	return homeDir + filename[1:]
+}
+
+// secretsFileName is the name of the default secrets file in the
+// configuration directory.
+const secretsFileName = "secrets.ini"
+
+// configDir returns the directory holding the default secrets file:
+// %APPDATA%\gauth on Windows and $XDG_CONFIG_HOME/gauth, by default
+// ~/.config/gauth, elsewhere.
+func configDir() (string, error) {
+	if runtime.GOOS == "windows" {
+		appData := os.Getenv("APPDATA")
+		if appData == "" {
+			return "", errors.New("%APPDATA% is not set")
+		}
+		return filepath.Join(appData, "gauth"), nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gauth"), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".config", "gauth"), nil
+}
+
+// defaultSecretsFile returns the secrets file used when none is given:
+// secrets.ini in dir, or else in configDir, where the legacy ~/.gauth
+// is used instead while secrets.ini does not exist. The directory is
+// created when missing.
+func defaultSecretsFile(dir string) (string, error) {
+	checkLegacy := dir == ""
+	if dir == "" {
+		var err error
+		if dir, err = configDir(); err != nil {
+			return "", err
+		}
+	}
+	filename := filepath.Join(expandHome(dir), secretsFileName)
+	if _, err := os.Stat(filename); err != nil && checkLegacy {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			legacy := filepath.Join(homeDir, ".gauth")
+			if info, err := os.Stat(legacy); err == nil && info.Mode().IsRegular() {
+				return legacy, nil
+			}
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// readFile is os.ReadFile reporting a missing file as
+// gauth.ErrFileNotFound.
+func readFile(filename string) ([]byte, error) {
+	content, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", gauth.ErrFileNotFound, filename)
+	}
+	return content, err
+}
+
+// writeFileAtomic replaces filename with data by writing a temporary
+// file in the same directory and renaming it over the original, so
+// readers never observe a partially written file.
+func writeFileAtomic(filename string, data []byte) error {
+	perm := os.FileMode(0600)
+	if info, err := os.Stat(filename); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestExpandHome(t *testing.T) {
+	t.Setenv("HOME", "/home/alice")
+	tests := map[string]string{
+		"~":                "/home/alice",
+		"~/secrets.ini":    "/home/alice/secrets.ini",
+		"~bob/secrets.ini": "~bob/secrets.ini",
+		"backup~/a.ini":    "backup~/a.ini",
+		"/tmp/a~b.ini":     "/tmp/a~b.ini",
+		"secrets.ini~":     "secrets.ini~",
+	}
+	for filename, want := range tests {
+		if got := expandHome(filename); got != want {
+			t.Errorf("expandHome(%q) = %q, want %q", filename, got, want)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gauth"
+)
+
+// cutGlobalFlags removes the flags with the given names, which every
+// operation accepts, from args wherever they appear and returns their
+// values by name.
+func cutGlobalFlags(args []string, names ...string) ([]string, map[string]string, error) {
+	rest := make([]string, 0, len(args))
+	values := make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || !slices.Contains(names, name) {
+			rest = append(rest, arg)
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("flag needs an argument: %s", arg)
+			}
+			i++
+			value = args[i]
+		}
+		values[name] = value
+	}
+	return rest, values, nil
+}
+
+// parseArgs parses flags that may appear before, between or after the
+// positional arguments, which are returned in order. The --config file
+// replaces the defaults of the flags it sets first.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	for name, value := range cliConfig.flagDefaults() {
+		// Setting the Value rather than calling fs.Set keeps the flag
+		// out of fs.Visit, which only reports flags given explicitly.
+		if f := fs.Lookup(name); f != nil {
+			if err := f.Value.Set(value); err != nil {
+				return nil, fmt.Errorf("invalid %s in config file: %w", name, err)
+			}
+		}
+	}
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// exitParseError exits after parseArgs failed. The flag package has
+// already reported the error, or printed the usage for -h.
+func exitParseError(err error) {
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	os.Exit(2)
+}
+
+// addConfigFlags registers the flags selecting the OTP parameters.
+func addConfigFlags(fs *flag.FlagSet, cfg *gauth.Config) {
+	fs.Func("algorithm", "HMAC algorithm: SHA1, SHA256 or SHA512", func(s string) error {
+		algorithm, err := gauth.ParseAlgorithm(s)
+		if err != nil {
+			return err
+		}
+		cfg.Algorithm = algorithm
+		return nil
+	})
+	fs.IntVar(&cfg.Digits, "digits", cfg.Digits, "number of digits in a code: 6, 7 or 8")
+	fs.Func("period", "seconds each time-based code is valid for (default 30)", func(s string) error {
+		period, err := strconv.ParseUint(s, 10, 0)
+		if err != nil || period == 0 {
+			return errors.New("period must be a positive number of seconds")
+		}
+		cfg.Period = uint(period)
+		return nil
+	})
+	fs.BoolFunc("steam", "write codes in the five character format of Steam Guard", func(string) error {
+		cfg.Format = gauth.Steam
+		return nil
+	})
+	fs.BoolFunc("blizzard", "write codes of eight digits like the Battle.net Authenticator", func(string) error {
+		cfg.Format = gauth.Blizzard
+		return nil
+	})
+}
+
+// addTimeFlag registers --time, which replaces the current time with a
+// Unix timestamp or an RFC 3339 date such as 2024-01-15T12:00:00Z.
+func addTimeFlag(fs *flag.FlagSet, t *time.Time) {
+	fs.Func("time", "use this Unix time or RFC 3339 date instead of now", func(s string) error {
+		if seconds, err := strconv.ParseInt(s, 10, 64); err == nil {
+			*t = time.Unix(seconds, 0)
+			return nil
+		}
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return errors.New("time must be a Unix timestamp or an RFC 3339 date")
+		}
+		*t = parsed
+		return nil
+	})
+}
+
+// addDryRunFlag registers --dry-run, which prints the changes to the
+// secrets file as a diff instead of writing them.
+func addDryRunFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&dryRun, "dry-run", false, "print the changes as a diff instead of writing them")
+}
+
+// secretSource holds the flags that read a secret from somewhere else
+// than the command line, where it would show up in ps output.
+type secretSource struct {
+	env    string
+	file   string
+	prompt bool // read the secret with readSecret
+}
+
+func addSecretFlags(fs *flag.FlagSet, src *secretSource) {
+	fs.StringVar(&src.env, "secret-env", "", "read the secret from this environment variable")
+	fs.StringVar(&src.file, "secret-file", "", "read the secret from this file")
+}
+
+// resolve returns the secret and the positional arguments that follow
+// it. Without --secret-env, --secret-file or a prompt the secret is
+// args[0], or the first line of stdin when args[0] is "-".
+func (src secretSource) resolve(args []string) (string, []string, error) {
+	switch {
+	case src.prompt:
+		secret, err := readSecret("secret: ")
+		if err != nil {
+			return "", nil, fmt.Errorf("can not read secret: %w", err)
+		}
+		return strings.TrimSpace(secret), args, nil
+	case src.env != "":
+		secret, ok := os.LookupEnv(src.env)
+		if !ok {
+			return "", nil, fmt.Errorf("environment variable %s is not set", src.env)
+		}
+		return strings.TrimSpace(secret), args, nil
+	case src.file != "":
+		content, err := os.ReadFile(expandHome(src.file))
+		if err != nil {
+			return "", nil, err
+		}
+		return strings.TrimSpace(string(content)), args, nil
+	case len(args) > 0 && args[0] == "-":
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", nil, fmt.Errorf("can not read secret from stdin: %w", err)
+		}
+		return strings.TrimSpace(line), args[1:], nil
+	case len(args) > 0:
+		return args[0], args[1:], nil
+	}
+	return "", nil, errors.New("require secret parameter")
+}
+
+// resolveOTPAuthURL lets a secret be given as an otpauth://totp/ URL:
+// it returns the secret of the URL and sets the parameters of cfg from
+// it, except those set explicitly by the flags of fs. Other secrets are
+// returned as they are.
+func resolveOTPAuthURL(secret string, cfg *gauth.Config, fs *flag.FlagSet) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(secret), "otpauth://") {
+		return secret, nil
+	}
+	otp, err := gauth.ParseOTPAuthURL(secret)
+	if err != nil {
+		return "", err
+	}
+	if otp.Type != "totp" {
+		return "", fmt.Errorf("%s URLs are not supported here", otp.Type)
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if !set["algorithm"] {
+		cfg.Algorithm = otp.Algorithm
+	}
+	if !set["digits"] {
+		cfg.Digits = otp.Digits
+	}
+	if !set["period"] {
+		cfg.Period = otp.Period
+	}
+	cfg.Issuer = otp.Issuer
+	return otp.Secret, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"gauth"
+)
+
+func TestSecretSource(t *testing.T) {
+	t.Setenv("TEST_GAUTH_SECRET", " JBSWY3DPEHPK3PXP\n")
+	file := writeTemp(t, "secret", "GEZDGNBVGY3TQOJQ\n")
+	tests := []struct {
+		src    secretSource
+		args   []string
+		secret string
+		rest   []string
+	}{
+		{secretSource{env: "TEST_GAUTH_SECRET"}, []string{"123456"}, "JBSWY3DPEHPK3PXP", []string{"123456"}},
+		{secretSource{file: file}, []string{"123456"}, "GEZDGNBVGY3TQOJQ", []string{"123456"}},
+		{secretSource{}, []string{"MZXW6YTBOI", "123456"}, "MZXW6YTBOI", []string{"123456"}},
+	}
+	for _, test := range tests {
+		secret, rest, err := test.src.resolve(test.args)
+		if err != nil || secret != test.secret || !slices.Equal(rest, test.rest) {
+			t.Errorf("%+v: resolve = %q, %q, %v, want %q, %q", test.src, secret, rest, err, test.secret, test.rest)
+		}
+	}
+
+	for _, src := range []secretSource{{env: "TEST_GAUTH_UNSET"}, {file: file + ".missing"}, {}} {
+		if _, _, err := src.resolve(nil); err == nil {
+			t.Errorf("%+v: resolve succeeded", src)
+		}
+	}
+}
+
+func TestSecretSourceStdin(t *testing.T) {
+	saved := stdin
+	t.Cleanup(func() { stdin = saved })
+	stdin = bufio.NewReader(strings.NewReader("  JBSWY3DPEHPK3PXP  \r\nignored\n"))
+	secret, rest, err := secretSource{}.resolve([]string{"-", "123456"})
+	if err != nil || secret != "JBSWY3DPEHPK3PXP" || !slices.Equal(rest, []string{"123456"}) {
+		t.Errorf("resolve = %q, %q, %v", secret, rest, err)
+	}
+
+	// A secret without a final newline is still read.
+	stdin = bufio.NewReader(strings.NewReader("GEZDGNBVGY3TQOJQ"))
+	if secret, _, err := (secretSource{}).resolve([]string{"-"}); err != nil || secret != "GEZDGNBVGY3TQOJQ" {
+		t.Errorf("resolve without newline = %q, %v", secret, err)
+	}
+	stdin = bufio.NewReader(strings.NewReader(""))
+	if _, _, err := (secretSource{}).resolve([]string{"-"}); err == nil {
+		t.Error("resolve of empty stdin succeeded")
+	}
+}
+
+func TestResolveOTPAuthURL(t *testing.T) {
+	tests := []struct {
+		url    string
+		flags  []string
+		secret string
+		want   gauth.Config
+	}{
+		{
+			"otpauth://totp/GitHub:alice?secret=JBSWY3DPEHPK3PXP&issuer=GitHub",
+			nil, "JBSWY3DPEHPK3PXP",
+			gauth.Config{Issuer: "GitHub", Algorithm: gauth.SHA1, Digits: 6, Period: 30, Window: 1},
+		},
+		{
+			"otpauth://totp/Google%3Aalice%40gmail.com?secret=jbswy3dpehpk3pxp&issuer=Google",
+			nil, "jbswy3dpehpk3pxp",
+			gauth.Config{Issuer: "Google", Algorithm: gauth.SHA1, Digits: 6, Period: 30, Window: 1},
+		},
+		{
+			"otpauth://totp/Amazon%20Web%20Services:alice@123456789012?secret=GEZDGNBVGY3TQOJQ&issuer=Amazon%20Web%20Services",
+			nil, "GEZDGNBVGY3TQOJQ",
+			gauth.Config{Issuer: "Amazon Web Services", Algorithm: gauth.SHA1, Digits: 6, Period: 30, Window: 1},
+		},
+		{
+			"OTPAUTH://TOTP/Example:bob@example.com?secret=JBSWY3DPEHPK3PXP&algorithm=SHA256&digits=8&period=60",
+			nil, "JBSWY3DPEHPK3PXP",
+			gauth.Config{Issuer: "Example", Algorithm: gauth.SHA256, Digits: 8, Period: 60, Window: 1},
+		},
+		{
+			"otpauth://totp/Example:bob?secret=JBSWY3DPEHPK3PXP&algorithm=SHA512&digits=8&period=60",
+			[]string{"--digits", "6", "--period", "30"}, "JBSWY3DPEHPK3PXP",
+			gauth.Config{Issuer: "Example", Algorithm: gauth.SHA512, Digits: 6, Period: 30, Window: 1},
+		},
+		{"JBSWY3DPEHPK3PXP", nil, "JBSWY3DPEHPK3PXP", gauth.DefaultConfig},
+	}
+	for _, test := range tests {
+		cfg := gauth.DefaultConfig
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		addConfigFlags(fs, &cfg)
+		if err := fs.Parse(test.flags); err != nil {
+			t.Fatal(err)
+		}
+		secret, err := resolveOTPAuthURL(test.url, &cfg, fs)
+		if err != nil {
+			t.Errorf("%s: %v", test.url, err)
+			continue
+		}
+		if secret != test.secret || !reflect.DeepEqual(cfg, test.want) {
+			t.Errorf("%s: got %q, %+v, want %q, %+v", test.url, secret, cfg, test.secret, test.want)
+		}
+	}
+
+	for _, url := range []string{
+		"otpauth://hotp/Example:bob?secret=JBSWY3DPEHPK3PXP&counter=0",
+		"otpauth://totp/Example:bob?issuer=Example",
+		"otpauth://totp/Example:bob?secret=JBSWY3DPEHPK3PXP&digits=x",
+	} {
+		cfg := gauth.DefaultConfig
+		if _, err := resolveOTPAuthURL(url, &cfg, flag.NewFlagSet("test", flag.ContinueOnError)); err == nil {
+			t.Errorf("%s: resolved", url)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// FuzzLoadINI checks that any secrets file loadINI reads, written back
+// and parsed again, holds the same sections.
+func FuzzLoadINI(f *testing.F) {
+	f.Add([]byte(""))
+	f.Add([]byte("; only a comment\n# and another\n"))
+	f.Add([]byte("[unterminated\nsecret = JBSWY3DPEHPK3PXP\n"))
+	f.Add([]byte("[a=b]\nsecret = JBSWY3DPEHPK3PXP\nuser = x=y\n"))
+	f.Add([]byte("[héllo wörld]\nuser = ユーザー\ndomain = 例え.jp\n"))
+	f.Add([]byte("\x00\xff\xfe[\x80]\n=\x01\r\n\xc3\x28 = \xe2\x82\n"))
+
+	// Encrypted-looking input must not wait for a passphrase.
+	saved := readSecret
+	f.Cleanup(func() { readSecret = saved })
+	readSecret = func(string) (string, error) { return "", errors.New("no terminal") }
+
+	filename := filepath.Join(f.TempDir(), "secrets.ini")
+	f.Fuzz(func(t *testing.T, content []byte) {
+		if err := os.WriteFile(filename, content, 0600); err != nil {
+			t.Fatal(err)
+		}
+		doc, err := loadINI(filename)
+		if err != nil {
+			if !isEncrypted(content) {
+				t.Fatalf("loadINI(%q): %v", content, err)
+			}
+			return
+		}
+		if !reflect.DeepEqual(parseINIDocument(doc.String()).config(), doc.config()) {
+			t.Errorf("sections of %q changed when written back", content)
+		}
+	})
+}
@@ -0,0 +1,498 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gauth"
+)
+
+// iniDocument is an INI file split into sections. It keeps every line,
+// including comments and blank lines, so that writing it back with
+// saveINI only changes what was edited.
+type iniDocument struct {
+	Sections []*iniSection
+}
+
+// iniSection is a section of an iniDocument. Comments holds the comment
+// and blank lines preceding the header, Lines the lines following it up
+// to the comments of the next section. Lines before the first header
+// form a section without Header.
+type iniSection struct {
+	Name     string
+	Comments []string
+	Header   string
+	Lines    []string
+}
+
+// loadINI reads and parses an INI file, decrypting it first when it
+// was encrypted with --encrypt.
+func loadINI(filename string) (*iniDocument, error) {
+	content, _, err := readSecretsFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseINIDocument(string(content)), nil
+}
+
+// saveINI writes doc to filename, encrypted again with the passphrase
+// it was read with.
+func saveINI(filename string, doc *iniDocument) error {
+	return writeSecretsFile(filename, []byte(doc.String()), passphrases[filename])
+}
+
+func parseINIDocument(text string) *iniDocument {
+	doc := &iniDocument{}
+	section := &iniSection{}
+	var comments []string
+	lines := strings.SplitAfter(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if line == "" {
+			continue
+		}
+		for continued(line) && i+1 < len(lines) && lines[i+1] != "" {
+			i++
+			line += lines[i]
+		}
+		if name, ok := sectionHeader(line); ok {
+			if section.Header != "" || len(section.Lines) > 0 {
+				doc.Sections = append(doc.Sections, section)
+			}
+			section = &iniSection{Name: name, Comments: comments, Header: line}
+			comments = nil
+			continue
+		}
+		if strings.TrimSpace(line) == "" || isComment(line) {
+			comments = append(comments, line)
+			continue
+		}
+		section.Lines = append(section.Lines, comments...)
+		section.Lines = append(section.Lines, line)
+		comments = nil
+	}
+	section.Lines = append(section.Lines, comments...)
+	if section.Header != "" || len(section.Lines) > 0 {
+		doc.Sections = append(doc.Sections, section)
+	}
+	return doc
+}
+
+// String returns the text of the document.
+func (doc *iniDocument) String() string {
+	var b strings.Builder
+	for _, section := range doc.Sections {
+		for _, line := range section.Comments {
+			b.WriteString(line)
+		}
+		b.WriteString(section.Header)
+		for _, line := range section.Lines {
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+// config returns the keys of each section by section name. Keys before
+// the first section are ignored.
+func (doc *iniDocument) config() map[string]map[string]string {
+	config := make(map[string]map[string]string)
+	for _, section := range doc.Sections {
+		if section.Header == "" {
+			continue
+		}
+		values := make(map[string]string)
+		for _, line := range section.Lines {
+			if key, value, ok := keyValue(line); ok {
+				values[key] = value
+			}
+		}
+		config[section.Name] = values
+	}
+	return config
+}
+
+// names returns the names of the sections in file order.
+func (doc *iniDocument) names() []string {
+	var names []string
+	for _, section := range doc.Sections {
+		if section.Header != "" {
+			names = append(names, section.Name)
+		}
+	}
+	return names
+}
+
+// section returns the section called name, matched exactly or else
+// case-insensitively.
+func (doc *iniDocument) section(name string) (*iniSection, bool) {
+	var found *iniSection
+	for _, section := range doc.Sections {
+		if section.Header == "" {
+			continue
+		}
+		if section.Name == name {
+			return section, true
+		}
+		if found == nil && strings.EqualFold(section.Name, name) {
+			found = section
+		}
+	}
+	return found, found != nil
+}
+
+// add appends a section holding values, separated from the previous
+// one by a blank line.
+func (doc *iniDocument) add(name string, values map[string]string) {
+	section := &iniSection{Name: name, Header: fmt.Sprintf("[%s]\n", name)}
+	if len(doc.Sections) > 0 {
+		doc.Sections[len(doc.Sections)-1].endLine()
+		section.Comments = []string{"\n"}
+	}
+	for _, key := range orderedKeys(values) {
+		if values[key] != "" {
+			section.Lines = append(section.Lines, fmt.Sprintf("%s = %s\n", key, values[key]))
+		}
+	}
+	doc.Sections = append(doc.Sections, section)
+}
+
+// remove deletes section together with the comments directly above its
+// header. Earlier comments, separated by a blank line, are kept.
+func (doc *iniDocument) remove(section *iniSection) {
+	i := slices.Index(doc.Sections, section)
+	if i < 0 {
+		return
+	}
+	keep := len(section.Comments)
+	for keep > 0 && isComment(section.Comments[keep-1]) {
+		keep--
+	}
+	kept := section.Comments[:keep]
+	doc.Sections = slices.Delete(doc.Sections, i, i+1)
+
+	// The blank line separating the removed section is not kept twice.
+	trimBlank := func() {
+		for len(kept) > 0 && strings.TrimSpace(kept[len(kept)-1]) == "" {
+			kept = kept[:len(kept)-1]
+		}
+	}
+	switch {
+	case i < len(doc.Sections):
+		next := doc.Sections[i]
+		if len(next.Comments) > 0 && strings.TrimSpace(next.Comments[0]) == "" {
+			trimBlank()
+		}
+		next.Comments = append(kept, next.Comments...)
+	case i > 0:
+		trimBlank()
+		prev := doc.Sections[i-1]
+		prev.Lines = append(prev.Lines, kept...)
+	case len(kept) > 0:
+		doc.Sections = []*iniSection{{Lines: kept}}
+	}
+}
+
+// set replaces the lines of existing keys in place and adds new keys
+// after the last key of the section.
+func (section *iniSection) set(values map[string]string) {
+	pending := make(map[string]string, len(values))
+	for key, value := range values {
+		pending[key] = value
+	}
+	last := -1
+	for i, line := range section.Lines {
+		key, _, ok := keyValue(line)
+		if !ok {
+			continue
+		}
+		last = i
+		if value, ok := pending[key]; ok {
+			section.Lines[i] = fmt.Sprintf("%s = %s\n", key, value)
+			delete(pending, key)
+		}
+	}
+	if last >= 0 && !strings.HasSuffix(section.Lines[last], "\n") {
+		section.Lines[last] += "\n"
+	}
+	if last < 0 && !strings.HasSuffix(section.Header, "\n") {
+		section.Header += "\n"
+	}
+	var added []string
+	for _, key := range orderedKeys(pending) {
+		if value, ok := pending[key]; ok {
+			added = append(added, fmt.Sprintf("%s = %s\n", key, value))
+		}
+	}
+	section.Lines = slices.Insert(section.Lines, last+1, added...)
+}
+
+// endLine terminates the last line of the section with a newline.
+func (section *iniSection) endLine() {
+	var last *string
+	switch {
+	case len(section.Lines) > 0:
+		last = &section.Lines[len(section.Lines)-1]
+	case section.Header != "":
+		last = &section.Header
+	case len(section.Comments) > 0:
+		last = &section.Comments[len(section.Comments)-1]
+	default:
+		return
+	}
+	if !strings.HasSuffix(*last, "\n") {
+		*last += "\n"
+	}
+}
+
+// continued reports whether line ends with a backslash continuing it
+// on the next line. Comments are never continued.
+func continued(line string) bool {
+	return strings.HasSuffix(line, "\n") && !isComment(line) &&
+		strings.HasSuffix(strings.TrimRight(line, "\r\n"), `\`)
+}
+
+var continuation = regexp.MustCompile(`\\\r?\n[ \t]*`)
+
+// unfold joins a line continued with backslashes into a single line,
+// dropping the backslashes and the indentation of the following lines.
+func unfold(line string) string {
+	body := strings.TrimRight(line, "\r\n")
+	return continuation.ReplaceAllString(body, "") + line[len(body):]
+}
+
+// keyValue splits a "key = value" line. Comments are not key lines.
+func keyValue(line string) (key, value string, ok bool) {
+	if isComment(line) {
+		return "", "", false
+	}
+	key, value, ok = strings.Cut(unfold(line), "=")
+	return strings.TrimSpace(key), strings.TrimSpace(value), ok
+}
+
+// sectionKeys lists the keys written by addSections, in order. Other
+// keys follow in alphabetical order.
+var sectionKeys = []string{"secret", "user", "domain", "algorithm", "digits", "period", "type", "counter"}
+
+// newSection is a section to be appended by addSections.
+type newSection struct {
+	Name   string
+	Values map[string]string
+}
+
+// addSection appends a new section holding values to the INI file,
+// creating the file when it does not exist yet.
+func addSection(filename, name string, values map[string]string) error {
+	_, err := addSections(filename, []newSection{{Name: name, Values: values}}, false)
+	return err
+}
+
+// addSections appends the sections to the INI file in a single write.
+// Sections whose name is already taken are an error, or are left out
+// and returned when skipExisting is set.
+func addSections(filename string, sections []newSection, skipExisting bool) (skipped []string, err error) {
+	if err := checkWritable(filename); err != nil {
+		return nil, err
+	}
+	doc, err := loadINI(filename)
+	if errors.Is(err, gauth.ErrFileNotFound) {
+		doc, err = &iniDocument{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	added := make([]newSection, 0, len(sections))
+	for _, section := range sections {
+		if !validName(section.Name) {
+			return nil, fmt.Errorf("invalid section name %q", section.Name)
+		}
+		for key, value := range section.Values {
+			if strings.ContainsAny(value, "\r\n") {
+				return nil, fmt.Errorf("invalid %s %q for section [%s]", key, value, section.Name)
+			}
+		}
+		if _, ok := doc.section(section.Name); ok {
+			if !skipExisting {
+				return nil, fmt.Errorf("section [%s] already exists", section.Name)
+			}
+			skipped = append(skipped, section.Name)
+			continue
+		}
+		doc.add(section.Name, section.Values)
+		added = append(added, section)
+	}
+	if len(added) == 0 {
+		return skipped, nil
+	}
+
+	if err := saveINI(filename, doc); err != nil {
+		return nil, err
+	}
+	if dryRun {
+		return skipped, nil
+	}
+	doc, err = loadINI(filename)
+	if err != nil {
+		return nil, err
+	}
+	config := doc.config()
+	for _, section := range added {
+		if _, ok := config[section.Name]; !ok {
+			return nil, fmt.Errorf("can not read back section [%s] from %s", section.Name, filename)
+		}
+	}
+	return skipped, nil
+}
+
+// validName reports whether name can be written as a section name,
+// or as a user or domain, without starting a new section or key.
+func validName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "[]\r\n")
+}
+
+// findSection looks name up case-insensitively and returns the name
+// the section actually has.
+func findSection(config map[string]map[string]string, name string) (string, bool) {
+	if _, ok := config[name]; ok {
+		return name, true
+	}
+	for key := range config {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+func orderedKeys(values map[string]string) []string {
+	keys := append([]string(nil), sectionKeys...)
+	var rest []string
+	for key := range values {
+		if !slices.Contains(sectionKeys, key) {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+// removeSection deletes the section matching name case-insensitively
+// from the INI file and returns its actual name. Lines outside the
+// section, including comments, are kept as they are.
+func removeSection(filename, name string) (string, error) {
+	if err := checkWritable(filename); err != nil {
+		return "", err
+	}
+	doc, err := loadINI(filename)
+	if err != nil {
+		return "", err
+	}
+	section, ok := doc.section(name)
+	if !ok {
+		return "", fmt.Errorf("section [%s] not found in %s", name, filename)
+	}
+	doc.remove(section)
+	return section.Name, saveINI(filename, doc)
+}
+
+// renameSection renames the section matching name case-insensitively
+// in the INI file to newName and returns its old name. Its keys and the
+// comments around it are kept. newName must not be taken by another
+// section.
+func renameSection(filename, name, newName string) (string, error) {
+	if err := checkWritable(filename); err != nil {
+		return "", err
+	}
+	if !validName(newName) {
+		return "", fmt.Errorf("invalid section name %q", newName)
+	}
+	doc, err := loadINI(filename)
+	if err != nil {
+		return "", err
+	}
+	section, ok := doc.section(name)
+	if !ok {
+		return "", fmt.Errorf("section [%s] not found in %s", name, filename)
+	}
+	if other, ok := doc.section(newName); ok && other != section {
+		return "", fmt.Errorf("section [%s] already exists", other.Name)
+	}
+	oldName := section.Name
+	section.Name = newName
+	section.Header = fmt.Sprintf("[%s]", newName) + section.Header[len(strings.TrimRight(section.Header, "\r\n")):]
+	return oldName, saveINI(filename, doc)
+}
+
+// copySection duplicates the section matching name case-insensitively
+// in the INI file as newName, copying its lines verbatim, and returns
+// the name of the source section. An existing newName section is an
+// error unless force is set, in which case its lines are replaced.
+func copySection(filename, name, newName string, force bool) (string, error) {
+	if err := checkWritable(filename); err != nil {
+		return "", err
+	}
+	if !validName(newName) {
+		return "", fmt.Errorf("invalid section name %q", newName)
+	}
+	doc, err := loadINI(filename)
+	if err != nil {
+		return "", err
+	}
+	section, ok := doc.section(name)
+	if !ok {
+		return "", fmt.Errorf("section [%s] not found in %s", name, filename)
+	}
+	lines := append([]string(nil), section.Lines...)
+	if dest, ok := doc.section(newName); ok {
+		switch {
+		case dest == section:
+			return "", fmt.Errorf("can not copy section [%s] onto itself", section.Name)
+		case !force:
+			return "", fmt.Errorf("section [%s] already exists", dest.Name)
+		}
+		dest.Lines = lines
+		dest.endLine()
+	} else {
+		doc.Sections[len(doc.Sections)-1].endLine()
+		copied := &iniSection{Name: newName, Comments: []string{"\n"}, Header: fmt.Sprintf("[%s]\n", newName), Lines: lines}
+		copied.endLine()
+		doc.Sections = append(doc.Sections, copied)
+	}
+	return section.Name, saveINI(filename, doc)
+}
+
+func sectionHeader(line string) (string, bool) {
+	line = strings.TrimSpace(unfold(line))
+	if len(line) < 2 || line[0] != '[' || line[len(line)-1] != ']' {
+		return "", false
+	}
+	return line[1 : len(line)-1], true
+}
+
+func isComment(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";")
+}
+
+// updateSection sets the given keys of the named section in the INI
+// file. Lines of existing keys are replaced in place and new keys are
+// added after the last key of the section; all other lines are kept.
+func updateSection(filename, name string, values map[string]string) error {
+	if err := checkWritable(filename); err != nil {
+		return err
+	}
+	doc, err := loadINI(filename)
+	if err != nil {
+		return err
+	}
+	section, ok := doc.section(name)
+	if !ok || section.Name != name {
+		return fmt.Errorf("section [%s] not found in %s", name, filename)
+	}
+	section.set(values)
+	return saveINI(filename, doc)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTemp writes content to a file called name in a new temporary
+// directory and returns its path.
+func writeTemp(t *testing.T, name, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func readTemp(t *testing.T, filename string) string {
+	t.Helper()
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestAddRemoveSection(t *testing.T) {
+	const original = "; my accounts\n[github]\nsecret = JBSWY3DPEHPK3PXP\n"
+	filename := writeTemp(t, "secrets.ini", original)
+	values := map[string]string{"secret": "GEZDGNBVGY3TQOJQ", "user": "alice", "domain": "example.com"}
+	if err := addSection(filename, "alice@example.com", values); err != nil {
+		t.Fatal(err)
+	}
+	config, _, err := loadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config["alice@example.com"]["secret"]; got != values["secret"] {
+		t.Fatalf("added section has secret %q, want %q", got, values["secret"])
+	}
+	if err := addSection(filename, "ALICE@example.com", values); err == nil {
+		t.Error("adding a section whose name is taken succeeded")
+	}
+
+	name, err := removeSection(filename, "Alice@Example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "alice@example.com" {
+		t.Errorf("removeSection returned %q, want the actual name", name)
+	}
+	if got := readTemp(t, filename); got != original {
+		t.Errorf("after add and remove the file is\n%s\nwant\n%s", got, original)
+	}
+	if _, err := removeSection(filename, "missing"); err == nil {
+		t.Error("removing a missing section succeeded")
+	}
+}
+
+func TestAddSectionInjection(t *testing.T) {
+	const original = "[github]\nsecret = JBSWY3DPEHPK3PXP\n"
+	filename := writeTemp(t, "secrets.ini", original)
+	for _, name := range []string{"", "evil]\nsecret = AAAA", "a[b", "a]b", "a\rb", "a\nb"} {
+		if err := addSection(filename, name, map[string]string{"secret": "GEZDGNBVGY3TQOJQ"}); err == nil {
+			t.Errorf("adding section %q succeeded", name)
+		}
+	}
+	for _, user := range []string{"alice\n[github]", "alice\rsecret = AAAA"} {
+		values := map[string]string{"secret": "GEZDGNBVGY3TQOJQ", "user": user}
+		if err := addSection(filename, "alice", values); err == nil {
+			t.Errorf("adding user %q succeeded", user)
+		}
+	}
+	if got := readTemp(t, filename); got != original {
+		t.Errorf("rejected sections changed the file to\n%q", got)
+	}
+}
+
+func TestUpdateSectionKeepsLayout(t *testing.T) {
+	const original = "; accounts\n\n" +
+		"[github]\n# personal\nsecret = JBSWY3DPEHPK3PXP\n  note=keep me\n\n" +
+		"; the hardware token\n[token]\nsecret=GEZDGNBVGY3TQOJQ\ntype = hotp\ncounter = 1\n; end"
+	filename := writeTemp(t, "secrets.ini", original)
+	if err := updateSection(filename, "token", map[string]string{"counter": "2", "user": "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	want := "; accounts\n\n" +
+		"[github]\n# personal\nsecret = JBSWY3DPEHPK3PXP\n  note=keep me\n\n" +
+		"; the hardware token\n[token]\nsecret=GEZDGNBVGY3TQOJQ\ntype = hotp\ncounter = 2\nuser = alice\n; end"
+	if got := readTemp(t, filename); got != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
+
+// FuzzINIDocument checks that parsing and writing back any text changes
+// nothing, and that the sections read from the written text are those
+// of the original.
+func FuzzINIDocument(f *testing.F) {
+	f.Add("; accounts\n[github]\nsecret = JBSWY3DPEHPK3PXP\n")
+	f.Add("key = before any section\n\n[a]\n\n# trailing")
+	f.Add("[a]\r\nsecret = X\r\n[b]\nsecret = Y")
+	f.Add("[a]\nsecret = ABC\\\n    DEF\n")
+	f.Add("[unterminated\n[]\n=\n")
+	f.Fuzz(func(t *testing.T, text string) {
+		doc := parseINIDocument(text)
+		written := doc.String()
+		if written != text {
+			t.Fatalf("parsing and writing %q gave %q", text, written)
+		}
+		if !reflect.DeepEqual(parseINIDocument(written).config(), doc.config()) {
+			t.Errorf("sections of %q changed when written back", text)
+		}
+	})
+}
+
+func TestContinuationLines(t *testing.T) {
+	const text = "[long\\\n  name]\n" +
+		"secret = JBSWY3DP\\\n    EHPK3PXP\n" +
+		"user = alice \\\r\n\tsmith\n" +
+		"; a comment \\\n" +
+		"domain = example.com\n"
+	doc := parseINIDocument(text)
+	want := map[string]map[string]string{
+		"longname": {"secret": "JBSWY3DPEHPK3PXP", "user": "alice smith", "domain": "example.com"},
+	}
+	if got := doc.config(); !reflect.DeepEqual(got, want) {
+		t.Errorf("config = %q, want %q", got, want)
+	}
+	if got := doc.String(); got != text {
+		t.Errorf("written back as %q", got)
+	}
+
+	section, _ := doc.section("longname")
+	section.set(map[string]string{"secret": "GEZDGNBVGY3TQOJQ"})
+	if got := doc.config()["longname"]; got["secret"] != "GEZDGNBVGY3TQOJQ" || got["user"] != "alice smith" {
+		t.Errorf("after replacing the continued secret: %q", got)
+	}
+}
+
+func TestRenameSectionCollision(t *testing.T) {
+	const original = "[github]\nsecret = JBSWY3DPEHPK3PXP\n\n[GitLab]\nsecret = GEZDGNBVGY3TQOJQ\n"
+	filename := writeTemp(t, "secrets.ini", original)
+	for _, newName := range []string{"GitLab", "gitlab", "GITLAB"} {
+		_, err := renameSection(filename, "github", newName)
+		if err == nil || !strings.Contains(err.Error(), "[GitLab] already exists") {
+			t.Errorf("renaming onto %s: error %v", newName, err)
+		}
+	}
+	if got := readTemp(t, filename); got != original {
+		t.Errorf("failed renames changed the file to\n%q", got)
+	}
+
+	oldName, err := renameSection(filename, "GITHUB", "GitHub")
+	if err != nil {
+		t.Fatalf("renaming a section to its own name in other case: %v", err)
+	}
+	if oldName != "github" {
+		t.Errorf("old name %q, want github", oldName)
+	}
+	want := "[GitHub]\nsecret = JBSWY3DPEHPK3PXP\n\n[GitLab]\nsecret = GEZDGNBVGY3TQOJQ\n"
+	if got := readTemp(t, filename); got != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestCopySectionAllFields(t *testing.T) {
+	const token = "[token]\n# the hardware one\nsecret = GEZDGNBVGY3TQOJQ\nuser = alice\ndomain = example.com\n" +
+		"algorithm = SHA256\ndigits = 8\nperiod = 60\nformat = numeric\ntype = hotp\ncounter = 7\n" +
+		"counter_updated = 2026-01-02T03:04:05Z\npin_prefix = 12\npin_suffix = 34\n"
+	filename := writeTemp(t, "secrets.ini", token)
+	if _, err := copySection(filename, "TOKEN", "spare", false); err != nil {
+		t.Fatal(err)
+	}
+	want := token + "\n[spare]" + strings.TrimPrefix(token, "[token]")
+	if got := readTemp(t, filename); got != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+	doc, err := loadINI(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts, err := fileAccounts(doc.config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accounts["spare"] != accounts["token"] {
+		t.Errorf("copy %+v differs from %+v", accounts["spare"], accounts["token"])
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keychainPrefix starts the service name of every keychain item written
+// by gauth; the section name follows it.
+const keychainPrefix = "gauth/"
+
+var errNoKeychain = errors.New("no keychain available (requires macOS security or Linux secret-tool)")
+
+// keychainSupported reports whether the platform keychain tool is
+// installed: security on macOS, secret-tool (Secret Service) on Linux.
+func keychainSupported() bool {
+	var tool string
+	switch runtime.GOOS {
+	case "darwin":
+		tool = "security"
+	case "linux", "freebsd", "openbsd":
+		tool = "secret-tool"
+	default:
+		return false
+	}
+	_, err := exec.LookPath(tool)
+	return err == nil
+}
+
+// keychainSet stores secret in the keychain item of section, replacing
+// any previous one.
+func keychainSet(section, secret string) error {
+	if !keychainSupported() {
+		return errNoKeychain
+	}
+	service := keychainPrefix + section
+	if runtime.GOOS == "darwin" {
+		// security -i reads the command from stdin, keeping the secret
+		// out of the argument list other users can see.
+		cmd := exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -a %s -s %s -l %s -w %s\n",
+			securityQuote(section), securityQuote(service), securityQuote(service), securityQuote(secret)))
+		return cmd.Run()
+	}
+	cmd := exec.Command("secret-tool", "store", "--label="+service,
+		"service", service, "application", "gauth", "account", section)
+	cmd.Stdin = strings.NewReader(secret)
+	return cmd.Run()
+}
+
+var securityQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// securityQuote quotes s as one argument of a security -i command line.
+func securityQuote(s string) string {
+	return `"` + securityQuoter.Replace(s) + `"`
+}
+
+// keychainGet returns the secret stored for section.
+func keychainGet(section string) (string, error) {
+	if !keychainSupported() {
+		return "", errNoKeychain
+	}
+	service := keychainPrefix + section
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", service, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", service)
+	}
+	out, err := cmd.Output()
+	if err != nil || len(out) == 0 {
+		return "", fmt.Errorf("no keychain item %s", service)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// keychainDelete removes the keychain item of section.
+func keychainDelete(section string) error {
+	if !keychainSupported() {
+		return errNoKeychain
+	}
+	service := keychainPrefix + section
+	if runtime.GOOS == "darwin" {
+		return exec.Command("security", "delete-generic-password", "-s", service).Run()
+	}
+	return exec.Command("secret-tool", "clear", "service", service).Run()
+}
+
+// keychainList returns the secrets of all keychain items written by
+// gauth, keyed by section name.
+func keychainList() (map[string]string, error) {
+	if !keychainSupported() {
+		return nil, errNoKeychain
+	}
+	secrets := make(map[string]string)
+	if runtime.GOOS == "darwin" {
+		out, err := exec.Command("security", "dump-keychain").Output()
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			_, service, ok := strings.Cut(strings.TrimSpace(scanner.Text()), `"svce"<blob>="`)
+			if !ok || !strings.HasPrefix(service, keychainPrefix) {
+				continue
+			}
+			section := strings.TrimPrefix(strings.TrimSuffix(service, `"`), keychainPrefix)
+			secret, err := keychainGet(section)
+			if err != nil {
+				return nil, err
+			}
+			secrets[section] = secret
+		}
+		return secrets, nil
+	}
+
+	out, err := exec.Command("secret-tool", "search", "--all", "--unlock", "application", "gauth").Output()
+	if err != nil {
+		// secret-tool fails when nothing matches.
+		return secrets, nil
+	}
+	var section string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " = ")
+		switch {
+		case !ok:
+		case key == "label" && strings.HasPrefix(value, keychainPrefix):
+			section = strings.TrimPrefix(value, keychainPrefix)
+		case key == "secret" && section != "":
+			secrets[section] = value
+			section = ""
+		}
+	}
+	return secrets, nil
+}
+
+// mergeKeychain fills in the secrets missing from the INI sections in
+// config from the keychain and adds the keychain items that have no
+// section of their own.
+func mergeKeychain(config map[string]map[string]string) error {
+	secrets, err := keychainList()
+	if err != nil {
+		return err
+	}
+	for name, secret := range secrets {
+		section, ok := config[name]
+		if !ok {
+			section = map[string]string{"user": name}
+			config[name] = section
+		}
+		if section["secret"] == "" {
+			section["secret"] = secret
+		}
+	}
+	return nil
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gauth"
+	"golang.org/x/term"
+)
+
+// account is a single entry of a secrets file.
+type account struct {
+	Name    string
+	Secret  string
+	User    string
+	Domain  string
+	Type    string // "totp" or "hotp"
+	Counter uint64
+	Config  gauth.Config
+	PIN     pin // wrapped around each code
+}
+
+// newAccount builds an account from the INI section called name, using
+// cfg for the parameters the section does not set.
+func newAccount(cfg gauth.Config, name string, section map[string]string) (account, error) {
+	acct := account{
+		Name:   name,
+		Secret: section["secret"],
+		User:   section["user"],
+		Domain: section["domain"],
+		Type:   "totp",
+		Config: cfg,
+		PIN:    pin{Prefix: section[pinPrefixKey], Suffix: section[pinSuffixKey]},
+	}
+	if value, ok := section["algorithm"]; ok {
+		algorithm, err := gauth.ParseAlgorithm(value)
+		if err != nil {
+			return acct, fmt.Errorf("invalid algorithm %q", value)
+		}
+		acct.Config.Algorithm = algorithm
+	}
+	if value, ok := section["digits"]; ok {
+		digits, err := strconv.Atoi(value)
+		if err != nil || digits < 6 || digits > 8 {
+			return acct, fmt.Errorf("invalid digits %q", value)
+		}
+		acct.Config.Digits = digits
+	}
+	if value, ok := section["format"]; ok {
+		format, err := gauth.ParseFormat(value)
+		if err != nil {
+			return acct, fmt.Errorf("invalid format %q", value)
+		}
+		acct.Config.Format = format
+	}
+	if value, ok := section["period"]; ok {
+		period, err := strconv.ParseUint(value, 10, 0)
+		if err != nil || period == 0 {
+			return acct, fmt.Errorf("invalid period %q", value)
+		}
+		acct.Config.Period = uint(period)
+	}
+	if value, ok := section["type"]; ok {
+		acct.Type = strings.ToLower(value)
+		if acct.Type != "totp" && acct.Type != "hotp" {
+			return acct, fmt.Errorf("invalid type %q", value)
+		}
+	}
+	if value, ok := section["counter"]; ok {
+		counter, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return acct, fmt.Errorf("invalid counter %q", value)
+		}
+		acct.Counter = counter
+	}
+	return acct, nil
+}
+
+// newAccounts builds the accounts of all sections of config, sorted by
+// section name.
+func newAccounts(cfg gauth.Config, config map[string]map[string]string) ([]account, error) {
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	table := make([]account, 0, len(keys))
+	for _, key := range keys {
+		section := config[key]
+		if section == nil {
+			continue
+		}
+		acct, err := newAccount(cfg, key, section)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry [%s]: %v", key, err)
+		}
+		table = append(table, acct)
+	}
+	return table, nil
+}
+
+// sortAccounts orders table, which newAccounts sorted by name, by user
+// or domain, or for "none" by order, the section names in file order.
+// Accounts missing from order, such as those only in the keychain, are
+// kept last.
+func sortAccounts(table []account, by string, order []string) {
+	switch by {
+	case "user":
+		sort.SliceStable(table, func(i, j int) bool {
+			return strings.ToLower(table[i].User) < strings.ToLower(table[j].User)
+		})
+	case "domain":
+		sort.SliceStable(table, func(i, j int) bool {
+			return strings.ToLower(table[i].Domain) < strings.ToLower(table[j].Domain)
+		})
+	case "none":
+		index := make(map[string]int, len(order))
+		for i, name := range order {
+			if _, ok := index[name]; !ok {
+				index[name] = i
+			}
+		}
+		position := func(acct account) int {
+			if i, ok := index[acct.Name]; ok {
+				return i
+			}
+			return len(order)
+		}
+		sort.SliceStable(table, func(i, j int) bool {
+			return position(table[i]) < position(table[j])
+		})
+	}
+}
+
+// findAccount looks up an account by section name, ignoring case, or
+// else by its user or domain when a single account matches name like
+// filterAccounts does.
+func findAccount(table []account, name string) (account, bool) {
+	for _, acct := range table {
+		if strings.EqualFold(acct.Name, name) {
+			return acct, true
+		}
+	}
+	if found := filterAccounts(table, name); len(found) == 1 {
+		return found[0], true
+	}
+	return account{}, false
+}
+
+// code returns the code of the account at time now, or for its counter
+// for HOTP accounts.
+func (acct account) code(now time.Time) (string, error) {
+	return acct.codeAt(now, 0)
+}
+
+// codeAt returns the code steps time steps, or counter values for HOTP,
+// after the current one, or before it when steps is negative. There is
+// no code before the first counter value.
+func (acct account) codeAt(now time.Time, steps int64) (string, error) {
+	counter := acct.Config.TimeStep(now)
+	if acct.Type == "hotp" {
+		counter = acct.Counter
+	}
+	if steps < 0 && counter < uint64(-steps) {
+		return "", nil
+	}
+	code, err := acct.Config.GenerateCode(acct.Secret, counter+uint64(steps))
+	if err != nil {
+		return "", err
+	}
+	return acct.PIN.wrap(code), nil
+}
+
+// filterAccounts returns the accounts whose profile, user or domain matches
+// query, ignoring case. A query containing glob metacharacters must
+// match one of them whole, any other query a substring of it.
+func filterAccounts(table []account, query string) []account {
+	query = strings.ToLower(query)
+	glob := strings.ContainsAny(query, "*?[")
+	matches := func(field string) bool {
+		field = strings.ToLower(field)
+		if glob {
+			ok, _ := path.Match(query, field)
+			return ok
+		}
+		return strings.Contains(field, query)
+	}
+
+	filtered := make([]account, 0, len(table))
+	for _, acct := range table {
+		if matches(acct.Name) || matches(acct.User) || matches(acct.Domain) {
+			filtered = append(filtered, acct)
+		}
+	}
+	return filtered
+}
+
+// verify checks code against the account: the codes of the previous,
+// current and next time step, or of the next three counter values for
+// HOTP accounts. On a match it returns the time step or counter value
+// of the code.
+func (acct account) verify(code string) (step uint64, ok bool, err error) {
+	code, ok = acct.PIN.strip(code)
+	if !ok {
+		return 0, false, nil
+	}
+	if acct.Type == "hotp" {
+		next, err := acct.Config.VerifyCounterBased(acct.Secret, code, int(acct.Counter)-1, 3)
+		return uint64(max(next, 0)), next != -1, err
+	}
+	now := clock()
+	offset, ok, err := acct.Config.VerifyTimeBasedAt(acct.Secret, code, 1, now)
+	return acct.Config.TimeStep(now) + uint64(offset), ok, err
+}
+
+// listOptions controls how listCode renders the accounts.
+type listOptions struct {
+	Continue bool
+	Once     bool          // with Continue, stop once every code has refreshed
+	Next     bool          // also show the code of the next time step
+	Prev     bool          // also show the code of the previous time step
+	Interval time.Duration // with Continue, the time between refreshes
+	Align    bool          // with Continue, refresh when the codes change
+	Stamp    bool          // for CSV, lead each row with its time and write the header once
+	Format   string
+	Style    string
+	Template *template.Template // with the "template" Format, applied to each templateRow
+
+	// With Continue, Reload replaces the accounts whenever the
+	// modification time or size of the file Watch changes.
+	Watch  string
+	Reload func() ([]account, error)
+}
+
+// codeRow is the current code of an account as printed by listCode.
+type codeRow struct {
+	Profile          string `json:"profile"`
+	User             string `json:"user"`
+	Domain           string `json:"domain"`
+	PrevCode         string `json:"prev_code,omitempty"`
+	Code             string `json:"code"`
+	NextCode         string `json:"next_code,omitempty"`
+	ExpiresAt        int64  `json:"expires_at"`
+	SecondsRemaining int64  `json:"seconds_remaining"`
+}
+
+// clock and sleep are the time source and the pause between refreshes
+// of listCode, replaced in tests.
+var (
+	clock = time.Now
+	sleep = time.Sleep
+)
+
+// listCode writes the codes of table to w, over and over with
+// opts.Continue.
+func listCode(table []account, opts listOptions, w io.Writer) int {
+	var refreshed time.Time // when every code printed first has expired
+	watched, _ := os.Stat(opts.Watch)
+	for first := true; ; first = false {
+		if opts.Reload != nil && !first {
+			if info, err := os.Stat(opts.Watch); err == nil && fileChanged(watched, info) {
+				watched = info
+				if reloaded, err := opts.Reload(); err != nil {
+					slog.Warn("can not reload", "file", opts.Watch, "error", err)
+				} else {
+					table = reloaded
+					if opts.Format == "table" {
+						fmt.Fprintln(w, "[reloaded]")
+					} else {
+						// Keep JSON and CSV output parseable.
+						slog.Info("reloaded", "file", opts.Watch)
+					}
+				}
+			}
+		}
+		now := clock()
+		if refreshed.IsZero() {
+			refreshed = now
+			for _, acct := range table {
+				if acct.Type == "totp" && acct.Config.Expiry(now).After(refreshed) {
+					refreshed = acct.Config.Expiry(now)
+				}
+			}
+		}
+		records := codeRows(table, opts, now)
+
+		switch opts.Format {
+		case "template":
+			for i, record := range records {
+				row := templateRow{codeRow: record, Epoch: table[i].Config.TimeStep(now)}
+				if table[i].Type == "hotp" {
+					row.Epoch = table[i].Counter
+				}
+				if err := opts.Template.Execute(w, row); err != nil {
+					slog.Error("can not apply template", "error", err)
+					return 1
+				}
+				fmt.Fprintln(w)
+			}
+		case "json":
+			json.NewEncoder(w).Encode(records)
+		case "csv":
+			cw := csv.NewWriter(w)
+			var header []string
+			if opts.Stamp {
+				header = append(header, "timestamp")
+			}
+			header = append(header, "profile", "user", "domain")
+			if opts.Prev {
+				header = append(header, "prev_code")
+			}
+			header = append(header, "code")
+			if opts.Next {
+				header = append(header, "next_code")
+			}
+			if first || !opts.Stamp {
+				cw.Write(append(header, "seconds_remaining"))
+			}
+			for _, record := range records {
+				var fields []string
+				if opts.Stamp {
+					fields = append(fields, now.UTC().Format(time.RFC3339))
+				}
+				fields = append(fields, record.Profile, record.User, record.Domain)
+				if opts.Prev {
+					fields = append(fields, record.PrevCode)
+				}
+				fields = append(fields, record.Code)
+				if opts.Next {
+					fields = append(fields, record.NextCode)
+				}
+				cw.Write(append(fields, strconv.FormatInt(record.SecondsRemaining, 10)))
+			}
+			cw.Flush()
+		default:
+			rows, align := tableRows(table, records, opts, colorEnabled())
+			fmt.Fprintln(w, tabulify(rows, opts.Style, align, terminalWidth(w)))
+		}
+		if !opts.Continue || opts.Once && !now.Before(refreshed) {
+			break
+		}
+		if opts.Format == "table" {
+			if cfg, ok := refreshConfig(table); ok {
+				fmt.Fprintln(w, refreshBar(cfg, now, terminalWidth(w)))
+			}
+			fmt.Fprintln(w, "press Ctrl+C to break ...")
+		}
+		sleep(refreshWait(table, opts, now))
+	}
+	return 0
+}
+
+// templateRow is a row of --list --template: the fields of codeRow and
+// the time step, or counter value, of the code.
+type templateRow struct {
+	codeRow
+	Epoch uint64
+}
+
+// codeRows returns the codes of table at time now.
+func codeRows(table []account, opts listOptions, now time.Time) []codeRow {
+	started := time.Now()
+	records := make([]codeRow, 0, len(table))
+	for _, record := range table {
+		code, err := record.code(now)
+		if err != nil {
+			slog.Debug("can not generate code", "account", record.Name, "error", err)
+			code = "invalid"
+		}
+		row := codeRow{
+			Profile: record.Name,
+			User:    record.User,
+			Domain:  record.Domain,
+			Code:    code,
+		}
+		if opts.Prev {
+			if row.PrevCode, err = record.codeAt(now, -1); err != nil {
+				row.PrevCode = "invalid"
+			}
+		}
+		if opts.Next {
+			if row.NextCode, err = record.codeAt(now, 1); err != nil {
+				row.NextCode = "invalid"
+			}
+		}
+		if record.Type == "totp" {
+			row.ExpiresAt = record.Config.Expiry(now).Unix()
+			row.SecondsRemaining = row.ExpiresAt - now.Unix()
+		}
+		records = append(records, row)
+	}
+	slog.Debug("generated codes", "accounts", len(records), "epoch", now.Unix(), "duration", time.Since(started))
+	return records
+}
+
+// tableRows returns the rows of the table listing records, the codes
+// of table, headed by the column names, and the alignment of each
+// column. Remaining times are colored with color.
+func tableRows(table []account, records []codeRow, opts listOptions, color bool) ([][]string, []alignment) {
+	header := []string{"Profile", "User", "Domain"}
+	align := []alignment{alignLeft, alignLeft, alignLeft}
+	if opts.Prev {
+		header = append(header, "Prev Code")
+		align = append(align, alignRight)
+	}
+	header = append(header, "Code")
+	align = append(align, alignRight)
+	if opts.Next {
+		header = append(header, "Next Code")
+		align = append(align, alignRight)
+	}
+	rows := [][]string{append(header, "Life Time")}
+	align = append(align, alignRight)
+	for i, record := range records {
+		life := fmt.Sprintf("%d (s)", record.SecondsRemaining)
+		if table[i].Type == "hotp" {
+			life = fmt.Sprintf("#%d", table[i].Counter)
+		} else if color {
+			life = colorize(life, lifeColor(record.SecondsRemaining, table[i].Config.Period))
+		}
+		row := []string{record.Profile, record.User, record.Domain}
+		if opts.Prev {
+			// The previous code may no longer be accepted.
+			prev := record.PrevCode
+			if color && prev != "" {
+				prev = colorize(prev, colorDim)
+			}
+			row = append(row, prev)
+		}
+		row = append(row, record.Code)
+		if opts.Next {
+			row = append(row, record.NextCode)
+		}
+		rows = append(rows, append(row, life))
+	}
+	return rows, align
+}
+
+// fileChanged reports whether a file described by old, which is nil
+// when it could not be read, has been modified as described by info.
+func fileChanged(old, info os.FileInfo) bool {
+	return old == nil || !info.ModTime().Equal(old.ModTime()) || info.Size() != old.Size()
+}
+
+// terminalWidth returns the width of the terminal w writes to, or 0 when
+// it is not a terminal.
+func terminalWidth(w io.Writer) int {
+	f, ok := w.(*os.File)
+	if !ok {
+		return 0
+	}
+	width, _, err := term.GetSize(int(f.Fd()))
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+// refreshWait returns how long --continue waits after showing the codes
+// of table at now: opts.Interval, or with opts.Align until the codes of
+// refreshConfig change.
+func refreshWait(table []account, opts listOptions, now time.Time) time.Duration {
+	if cfg, ok := refreshConfig(table); ok && opts.Align {
+		return cfg.Expiry(now).Sub(clock())
+	}
+	return opts.Interval
+}
+
+// refreshConfig returns the parameters of the time-based account whose
+// codes refresh first, or false when every account is counter-based.
+func refreshConfig(table []account) (gauth.Config, bool) {
+	var cfg gauth.Config
+	found := false
+	for _, acct := range table {
+		if acct.Type == "totp" && (!found || acct.Config.Period < cfg.Period) {
+			cfg, found = acct.Config, true
+		}
+	}
+	return cfg, found
+}
+
+// advanceCounters offers, for --list --interactive, to move each HOTP
+// account of the INI file past the code just displayed, writing the new
+// counter back on confirmation. The answers are read with readAnswer.
+func advanceCounters(filename string, table []account) error {
+	for _, acct := range table {
+		if acct.Type != "hotp" {
+			continue
+		}
+		answer, err := readAnswer(fmt.Sprintf("advance the counter of [%s] to %d? [y/N] ", acct.Name, acct.Counter+1))
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			continue
+		}
+		values := map[string]string{
+			"counter":         strconv.FormatUint(acct.Counter+1, 10),
+			counterUpdatedKey: time.Now().UTC().Format(time.RFC3339),
+		}
+		if err := updateSection(filename, acct.Name, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
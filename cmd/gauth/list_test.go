@@ -0,0 +1,480 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"gauth"
+)
+
+// loadAccounts reads the accounts of an INI file with the default
+// parameters.
+func loadAccounts(t *testing.T, filename string) []account {
+	t.Helper()
+	config, _, err := loadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := newAccounts(gauth.DefaultConfig, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+// mustFindAccount returns the account of table with the section name.
+func mustFindAccount(t *testing.T, table []account, name string) account {
+	t.Helper()
+	for _, acct := range table {
+		if acct.Name == name {
+			return acct
+		}
+	}
+	t.Fatalf("no account %q", name)
+	return account{}
+}
+
+func TestAdvanceCounters(t *testing.T) {
+	filename := writeTemp(t, "secrets.ini",
+		"[totp]\nsecret = JBSWY3DPEHPK3PXP\n"+
+			"[yes]\nsecret = GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\ntype = hotp\ncounter = 5\n"+
+			"[no]\nsecret = GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\ntype = hotp\ncounter = 7\n")
+	table := loadAccounts(t, filename)
+	if code, err := mustFindAccount(t, table, "yes").codeAt(time.Now(), 0); err != nil || code != "254676" {
+		t.Fatalf("code of counter 5 = %s, %v, want 254676", code, err)
+	}
+
+	// The accounts are sorted by name, so [no] is asked first.
+	saved, savedTerminal := stdin, isTerminal
+	t.Cleanup(func() { stdin, isTerminal, terminalOnly = saved, savedTerminal, false })
+	stdin = bufio.NewReader(strings.NewReader("n\n Y \r\n"))
+	if err := advanceCounters(filename, table); err != nil {
+		t.Fatal(err)
+	}
+
+	table = loadAccounts(t, filename)
+	yes, no := mustFindAccount(t, table, "yes"), mustFindAccount(t, table, "no")
+	if yes.Counter != 6 || no.Counter != 7 {
+		t.Errorf("counters after advancing = %d, %d, want 6, 7", yes.Counter, no.Counter)
+	}
+	if code, _ := yes.codeAt(time.Now(), 0); code != "287922" {
+		t.Errorf("code of counter 6 = %s, want 287922", code)
+	}
+
+	// Without an answer, no counter moves.
+	before := readTemp(t, filename)
+	if err := advanceCounters(filename, table); err == nil {
+		t.Error("advanceCounters succeeded without answers")
+	}
+	stdin = bufio.NewReader(strings.NewReader("y\ny\n"))
+	terminalOnly, isTerminal = true, func(int) bool { return false }
+	if err := advanceCounters(filename, table); err == nil {
+		t.Error("advanceCounters read answers from a stdin that is not a terminal")
+	}
+	if readTemp(t, filename) != before {
+		t.Error("counters changed without answers")
+	}
+}
+
+func TestFilterAccounts(t *testing.T) {
+	table := []account{
+		{Name: "github", User: "alice", Domain: "github.com"},
+		{Name: "work", User: "bob", Domain: "example.com"},
+		{Name: "home", User: "Alice", Domain: "example.org"},
+	}
+	names := func(table []account) string {
+		var names []string
+		for _, acct := range table {
+			names = append(names, acct.Name)
+		}
+		return strings.Join(names, ",")
+	}
+	tests := []struct {
+		query, want string
+	}{
+		{"alice", "github,home"},
+		{"EXAMPLE", "work,home"},
+		{"git", "github"},
+		{"*.com", "github,work"},
+		{"h?me", "home"},
+		{"nobody", ""},
+	}
+	for _, test := range tests {
+		if got := names(filterAccounts(table, test.query)); got != test.want {
+			t.Errorf("filterAccounts(%q) = %s, want %s", test.query, got, test.want)
+		}
+	}
+}
+
+// fakeClock makes listCode start at the Unix time start and sleep by
+// moving its clock forward. It returns the pauses slept so far.
+func fakeClock(t *testing.T, start int64) *[]time.Duration {
+	t.Helper()
+	savedClock, savedSleep := clock, sleep
+	t.Cleanup(func() { clock, sleep = savedClock, savedSleep })
+	now := time.Unix(start, 0)
+	var slept []time.Duration
+	clock = func() time.Time { return now }
+	sleep = func(d time.Duration) {
+		slept = append(slept, d)
+		now = now.Add(d)
+	}
+	return &slept
+}
+
+func TestListCodeOnce(t *testing.T) {
+	table := []account{{Name: "github", Secret: "JBSWY3DPEHPK3PXP", Type: "totp", Config: gauth.DefaultConfig}}
+	tests := []struct {
+		opts listOptions
+		want []int64 // seconds remaining of each listing
+	}{
+		{listOptions{Interval: 10 * time.Second}, []int64{15, 5, 25}},
+		{listOptions{Interval: 10 * time.Second, Align: true}, []int64{15, 30}},
+	}
+	for _, test := range tests {
+		fakeClock(t, 1000000005) // 15 seconds before the code expires
+		var out strings.Builder
+		test.opts.Continue, test.opts.Once, test.opts.Format = true, true, "json"
+		if status := listCode(table, test.opts, &out); status != 0 {
+			t.Fatalf("listCode returned %d", status)
+		}
+		var got []int64
+		dec := json.NewDecoder(strings.NewReader(out.String()))
+		for dec.More() {
+			var records []codeRow
+			if err := dec.Decode(&records); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, records[0].SecondsRemaining)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("align %v: listed with %v seconds remaining, want %v", test.opts.Align, got, test.want)
+		}
+	}
+}
+
+func TestSortAccounts(t *testing.T) {
+	filename := writeTemp(t, "secrets.ini",
+		"[zeta]\nsecret = JBSWY3DPEHPK3PXP\nuser = bob\ndomain = a.com\n"+
+			"[alpha]\nsecret = JBSWY3DPEHPK3PXP\nuser = Carol\ndomain = c.com\n"+
+			"[mid]\nsecret = JBSWY3DPEHPK3PXP\nuser = alice\ndomain = B.com\n")
+	config, order, err := loadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		by   string
+		want string
+	}{
+		{"name", "alpha,mid,zeta"},
+		{"user", "mid,zeta,alpha"},
+		{"domain", "zeta,mid,alpha"},
+		{"none", "zeta,alpha,mid"},
+	}
+	for _, test := range tests {
+		table, err := newAccounts(gauth.DefaultConfig, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.by == "none" {
+			// Only in the keychain, so missing from the file order.
+			table = append([]account{{Name: "keychain"}}, table...)
+			test.want += ",keychain"
+		}
+		sortAccounts(table, test.by, order)
+		var names []string
+		for _, acct := range table {
+			names = append(names, acct.Name)
+		}
+		if got := strings.Join(names, ","); got != test.want {
+			t.Errorf("--sort %s: %s, want %s", test.by, got, test.want)
+		}
+	}
+}
+
+// rfcAccount is a TOTP account with the secret and parameters of the
+// RFC 6238 SHA1 test vectors.
+var rfcAccount = account{
+	Name: "rfc", Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Type: "totp",
+	Config: gauth.Config{Algorithm: gauth.SHA1, Digits: 8, Period: 30},
+}
+
+func TestCodeRowsNext(t *testing.T) {
+	records := codeRows([]account{rfcAccount}, listOptions{Next: true}, time.Unix(59, 0))
+	// The codes of the counters 1 and 2 of RFC 4226 appendix D.
+	if got := records[0]; got.Code != "94287082" || got.NextCode != "37359152" {
+		t.Errorf("code and next code = %s, %s, want 94287082, 37359152", got.Code, got.NextCode)
+	}
+	rows, align := tableRows([]account{rfcAccount}, records, listOptions{Next: true}, false)
+	if want := []string{"Profile", "User", "Domain", "Code", "Next Code", "Life Time"}; !slices.Equal(rows[0], want) {
+		t.Errorf("header = %q, want %q", rows[0], want)
+	}
+	if rows[1][4] != "37359152" || align[4] != alignRight {
+		t.Errorf("next code column = %q, aligned %v", rows[1][4], align[4])
+	}
+}
+
+func TestCodeRowsPrev(t *testing.T) {
+	opts := listOptions{Prev: true}
+	records := codeRows([]account{rfcAccount}, opts, time.Unix(59, 0))
+	if got := records[0]; got.Code != "94287082" || got.PrevCode != "84755224" {
+		t.Errorf("code and previous code = %s, %s, want 94287082, 84755224", got.Code, got.PrevCode)
+	}
+	rows, _ := tableRows([]account{rfcAccount}, records, opts, true)
+	if want := []string{"Profile", "User", "Domain", "Prev Code", "Code", "Life Time"}; !slices.Equal(rows[0], want) {
+		t.Errorf("header = %q, want %q", rows[0], want)
+	}
+	if want := colorize("84755224", colorDim); rows[1][3] != want || rows[1][4] != "94287082" {
+		t.Errorf("codes = %q, %q, want the previous one dimmed", rows[1][3], rows[1][4])
+	}
+	rows, _ = tableRows([]account{rfcAccount}, records, opts, false)
+	if rows[1][3] != "84755224" {
+		t.Errorf("previous code without color = %q", rows[1][3])
+	}
+}
+
+// TestListCodeAlignRefresh checks that --align-refresh sleeps until the
+// codes of the account with the shortest period expire.
+func TestListCodeAlignRefresh(t *testing.T) {
+	slow := rfcAccount
+	slow.Name, slow.Config.Period = "slow", 60
+	table := []account{slow, rfcAccount}
+	// 25 seconds before the 30 second codes expire, 55 before the others.
+	slept := fakeClock(t, 1000000025)
+	opts := listOptions{Continue: true, Once: true, Align: true, Interval: time.Second, Format: "json"}
+	if status := listCode(table, opts, io.Discard); status != 0 {
+		t.Fatalf("listCode returned %d", status)
+	}
+	if want := []time.Duration{25 * time.Second, 30 * time.Second}; !slices.Equal(*slept, want) {
+		t.Errorf("slept %v, want %v", *slept, want)
+	}
+}
+
+func TestRefreshWait(t *testing.T) {
+	fakeClock(t, 1000000025)
+	slow := rfcAccount
+	slow.Config.Period = 60
+	hotp := account{Name: "token", Type: "hotp", Config: gauth.DefaultConfig}
+	tests := []struct {
+		table []account
+		align bool
+		want  time.Duration
+	}{
+		{[]account{slow, rfcAccount}, false, 2 * time.Second},
+		{[]account{slow, rfcAccount}, true, 25 * time.Second}, // the 30 second codes
+		{[]account{slow}, true, 55 * time.Second},
+		{[]account{hotp}, true, 2 * time.Second},
+	}
+	for i, test := range tests {
+		opts := listOptions{Interval: 2 * time.Second, Align: test.align}
+		if got := refreshWait(test.table, opts, clock()); got != test.want {
+			t.Errorf("case %d: waits %v, want %v", i, got, test.want)
+		}
+	}
+
+	// Time spent drawing is taken off the wait.
+	now := clock()
+	sleep(time.Second)
+	if got := refreshWait([]account{rfcAccount}, listOptions{Align: true}, now); got != 24*time.Second {
+		t.Errorf("a second after drawing: waits %v, want 24s", got)
+	}
+}
+
+func TestListCodeWatchFile(t *testing.T) {
+	filename := writeTemp(t, "secrets.ini", "[github]\nsecret = JBSWY3DPEHPK3PXP\n")
+	fakeClock(t, 1000000025)
+	tick := sleep
+	sleep = func(d time.Duration) {
+		// Another gauth --add while the list runs.
+		f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString("[gitlab]\nsecret = GEZDGNBVGY3TQOJQ\n")
+		f.Close()
+		tick(d)
+	}
+	opts := listOptions{
+		Continue: true, Once: true, Align: true, Format: "table", Style: "2",
+		Watch: filename,
+		Reload: func() ([]account, error) {
+			return loadAccounts(t, filename), nil
+		},
+	}
+	var buf bytes.Buffer
+	if status := listCode(loadAccounts(t, filename), opts, &buf); status != 0 {
+		t.Fatalf("listCode returned %d", status)
+	}
+	before, after, ok := strings.Cut(buf.String(), "[reloaded]\n")
+	if !ok {
+		t.Fatalf("no [reloaded] in\n%s", buf.String())
+	}
+	if strings.Contains(before, "gitlab") || !strings.Contains(before, "github") {
+		t.Errorf("listing before the change:\n%s", before)
+	}
+	if !strings.Contains(after, "gitlab") || !strings.Contains(after, "github") {
+		t.Errorf("listing after the change:\n%s", after)
+	}
+}
+
+func TestListCodeWriter(t *testing.T) {
+	fakeClock(t, 59)
+	table := []account{rfcAccount}
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{"table", []string{"| rfc ", "| 94287082 |", " 1 (s) |"}},
+		{"json", []string{`"profile":"rfc"`, `"code":"94287082"`, `"expires_at":60`}},
+		{"csv", []string{"profile,user,domain,code,seconds_remaining\n", "rfc,,,94287082,1\n"}},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		opts := listOptions{Format: test.format, Style: "2"}
+		if status := listCode(table, opts, &buf); status != 0 {
+			t.Fatalf("listCode returned %d", status)
+		}
+		for _, want := range test.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s output\n%s\ndoes not contain %q", test.format, buf.String(), want)
+			}
+		}
+	}
+}
+
+func TestListCodeTemplate(t *testing.T) {
+	fakeClock(t, 59)
+	totp := rfcAccount
+	totp.User, totp.Domain = "alice", "example.com"
+	hotp := account{
+		Name: "token", Secret: "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", Type: "hotp", Counter: 5,
+		Config: gauth.DefaultConfig,
+	}
+	tests := []struct {
+		template string
+		opts     listOptions
+		want     string
+	}{
+		{"{{.User}}:{{.Domain}}:{{.Code}}", listOptions{}, "alice:example.com:94287082\n::254676\n"},
+		{"{{.Profile}} {{.Epoch}} {{.SecondsRemaining}} {{.ExpiresAt}}", listOptions{}, "rfc 1 1 60\ntoken 5 0 0\n"},
+		{"{{with .}}{{.Profile}}={{slice .Code 0 4}}{{end}}", listOptions{}, "rfc=9428\ntoken=2546\n"},
+		{"{{.PrevCode}} {{.Code}} {{.NextCode}}", listOptions{Prev: true, Next: true},
+			"84755224 94287082 37359152\n" + "338314 254676 287922\n"},
+		{`{{printf "%-6s|" .Profile}}{{if .User}}{{.User}}{{else}}-{{end}}`, listOptions{}, "rfc   |alice\ntoken |-\n"},
+	}
+	for _, test := range tests {
+		opts := test.opts
+		opts.Format = "template"
+		opts.Template = template.Must(template.New("row").Parse(test.template))
+		var buf bytes.Buffer
+		if status := listCode([]account{totp, hotp}, opts, &buf); status != 0 {
+			t.Fatalf("%s: listCode returned %d", test.template, status)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.template, got, test.want)
+		}
+	}
+
+	opts := listOptions{Format: "template", Template: template.Must(template.New("row").Parse("{{.Secret}}"))}
+	if status := listCode([]account{totp}, opts, io.Discard); status != 1 {
+		t.Errorf("template of a missing field: listCode returned %d, want 1", status)
+	}
+}
+
+func TestListCodeMixedPeriods(t *testing.T) {
+	filename := writeTemp(t, "secrets.ini",
+		"[fast]\nsecret = JBSWY3DPEHPK3PXP\n[slow]\nsecret = JBSWY3DPEHPK3PXP\nperiod = 60\n")
+	// 25 seconds before the 30 second codes change, 55 before the others.
+	fakeClock(t, 1000000025)
+	table := loadAccounts(t, filename)
+
+	var buf bytes.Buffer
+	if status := listCode(table, listOptions{Format: "json"}, &buf); status != 0 {
+		t.Fatalf("listCode returned %d", status)
+	}
+	var records []codeRow
+	if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]struct {
+		period    uint
+		remaining int64
+	}{"fast": {30, 25}, "slow": {60, 55}}
+	for _, record := range records {
+		w := want[record.Profile]
+		cfg := gauth.DefaultConfig
+		cfg.Period = w.period
+		code, err := cfg.GenerateTimeBasedAt("JBSWY3DPEHPK3PXP", clock())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Code != code || record.SecondsRemaining != w.remaining || record.ExpiresAt != 1000000025+w.remaining {
+			t.Errorf("%s: %+v, want code %s expiring in %ds", record.Profile, record, code, w.remaining)
+		}
+	}
+	if len(records) != 2 || records[0].Code == records[1].Code {
+		t.Errorf("records %+v, want two different codes", records)
+	}
+
+	buf.Reset()
+	listCode(table, listOptions{Format: "table", Style: "2"}, &buf)
+	for _, life := range []string{" 25 (s) |", " 55 (s) |"} {
+		if !strings.Contains(buf.String(), life) {
+			t.Errorf("table\n%s\ndoes not contain %q", buf.String(), life)
+		}
+	}
+}
+
+func TestListCodeAlgorithm(t *testing.T) {
+	// The seeds of RFC 6238 appendix B, sized to each hash.
+	filename := writeTemp(t, "secrets.ini",
+		"[sha256]\nsecret = GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZA====\nalgorithm = SHA256\ndigits = 8\n"+
+			"[sha512]\nsecret = GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"+
+			"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQGEZDGNA=\nalgorithm = sha512\ndigits = 8\n")
+	tests := []struct {
+		time int64
+		want string
+	}{
+		{59, "sha256,,,46119246,1\nsha512,,,90693936,1\n"},
+		{1111111109, "sha256,,,68084774,1\nsha512,,,25091201,1\n"},
+	}
+	for _, test := range tests {
+		fakeClock(t, test.time)
+		var buf bytes.Buffer
+		if status := listCode(loadAccounts(t, filename), listOptions{Format: "csv"}, &buf); status != 0 {
+			t.Fatalf("listCode returned %d", status)
+		}
+		want := "profile,user,domain,code,seconds_remaining\n" + test.want
+		if got := buf.String(); got != want {
+			t.Errorf("at %d: got\n%s\nwant\n%s", test.time, got, want)
+		}
+	}
+}
+
+func TestListCodeMixedDigits(t *testing.T) {
+	filename := writeTemp(t, "secrets.ini",
+		"[six]\nsecret = GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\n[eight]\nsecret = GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\ndigits = 8\n")
+	fakeClock(t, 59)
+	var buf bytes.Buffer
+	if status := listCode(loadAccounts(t, filename), listOptions{Format: "table", Style: "2"}, &buf); status != 0 {
+		t.Fatalf("listCode returned %d", status)
+	}
+	want := "+---------+------+--------+----------+-----------+\n" +
+		"| Profile | User | Domain |     Code | Life Time |\n" +
+		"+---------+------+--------+----------+-----------+\n" +
+		"| eight   |      |        | 94287082 |     1 (s) |\n" +
+		"+---------+------+--------+----------+-----------+\n" +
+		"| six     |      |        |   287082 |     1 (s) |\n" +
+		"+---------+------+--------+----------+-----------+\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// Timing of lockFile: how often to retry, how long to wait, and when a
+// lock left behind by a crashed process is removed.
+const (
+	lockRetry   = 10 * time.Millisecond
+	lockTimeout = 5 * time.Second
+	lockStale   = 30 * time.Second
+)
+
+// lockFile takes an exclusive lock on filename, held by creating
+// filename.lock, and returns the function releasing it. Unlike flock(2)
+// this works the same on every platform.
+func lockFile(filename string) (unlock func(), err error) {
+	lock := filename + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is locked, remove %s if no other gauth is running", filename, lock)
+		}
+		time.Sleep(lockRetry)
+	}
+}
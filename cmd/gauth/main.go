@@ -0,0 +1,1571 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gauth"
+)
+
+func main() {
+	args, global, err := cutGlobalFlags(os.Args, "log-level", "log-format", "config")
+	if err == nil {
+		err = setupLogging(cmp.Or(global["log-level"], "info"), cmp.Or(global["log-format"], "text"))
+	}
+	if err == nil && global["config"] != "" {
+		cliConfig, err = loadCLIConfig(expandHome(global["config"]))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(args) <= 1 {
+		fmt.Println("usage: gauth <operation> [...]")
+		fmt.Println("operations:")
+		fmt.Println("    gauth {-c --create} [user] [domain] [--qr=false | --qr-output file.png] [--hotp] [--key-bits N] [--issuer I] [--recovery-codes N] [--save file [--dry-run]] [--output-uri | --output-secret | --output-barcode] [options]")
+		fmt.Println("    gauth {-c --create} [user] [domain] --count N [--format {list,json}] [--qr-output file.png] [--output-uri | --output-secret] [options]")
+		fmt.Println("    gauth {-v --verify} {secret | otpauth-url | -} code [--clock-drift] [--window N | --strict] [--time T] [--quiet] [--audit-log F] [--state-file F] [--label L] [--pin-prefix P] [--pin-suffix P] [options]")
+		fmt.Println("    gauth {-v --verify} {--secret-env VAR | --secret-file F} code [options]")
+		fmt.Println("    gauth {-v --verify} {secret | otpauth-url | --secret-env VAR | --secret-file F} --interactive [options]")
+		fmt.Println("    gauth {-d --display} {secret | otpauth-url | - | --interactive} [--qr] [--copy [--clear-on-expire]] [--watch] [--time T] [--quiet] [--pin-prefix P] [--pin-suffix P] [options]")
+		fmt.Println("    gauth {-d --display} {--secret-env VAR | --secret-file F} [options]")
+		fmt.Println("    gauth --hotp secret counter [options]")
+		fmt.Println("    gauth --hotp-verify secret code counter [--window N] [options]")
+		fmt.Println("    gauth {-l --list} [filename | --config-dir D] [--continue [--once] [--interval D | --align-refresh] [--watch-file] [--no-tui]] [--interactive] [--format {table,json,csv} [--timestamp] | --template T] [--style S] [--sort {name,user,domain,none}] [--prev] [--next] [--search Q] [--copy section] [--keychain] [options]")
+		fmt.Println("    gauth {-a --add} filename [--user U] [--domain D] [--profile P] [--secret S] [--qr=false | --qr-output file.png] [--keychain] [--dry-run]")
+		fmt.Println("    gauth {-r --remove} filename section [--keychain] [--dry-run]")
+		fmt.Println("    gauth --rename filename section new-section [--dry-run]")
+		fmt.Println("    gauth --copy-entry filename section new-section [--force] [--dry-run]")
+		fmt.Println("    gauth --count filename")
+		fmt.Println("    gauth --check-expiry filename")
+		fmt.Println("    gauth --list-recovery filename [section]")
+		fmt.Println("    gauth --print-audit filename [--style S]")
+		fmt.Println("    gauth --diff file1 file2")
+		fmt.Println("    gauth --merge base overlay --output F [--on-conflict {keep-base,keep-overlay,error,prompt}] [--dry-run]")
+		fmt.Println("    gauth --validate-secret secret")
+		fmt.Println("    gauth --export filename [--qr] [options]")
+		fmt.Println("    gauth --import filename [--url {otpauth-migration,otpauth}://...] [--dry-run]")
+		fmt.Println("    gauth --save-toml filename [output.toml]")
+		fmt.Println("    gauth --save-yaml filename [output.yaml]")
+		fmt.Println("    gauth --backup filename output[.gpg] [--dry-run]")
+		fmt.Println("    gauth --restore backup filename [--dry-run]")
+		fmt.Println("    gauth --daemon --file filename [--socket path] [options]")
+		fmt.Println("    gauth --client account [--socket path]")
+		fmt.Println("    gauth --serve --file filename --token T [--addr host:port] [--tls-cert F --tls-key F] [--request-timeout D] [--metrics] [--scim] [--state-file F] [options]")
+		fmt.Println("    gauth --selftest")
+		fmt.Println("    gauth --benchmark [--iterations N] [--style S]")
+		fmt.Println("    gauth --completion {bash,zsh,fish}")
+		fmt.Println("    gauth --version [--format {text,json}]")
+		fmt.Println("    gauth --encrypt filename")
+		fmt.Println("    gauth --decrypt filename")
+		fmt.Println("options:")
+		fmt.Println("    --algorithm {SHA1,SHA256,SHA512}  HMAC algorithm (default SHA1)")
+		fmt.Println("    --digits {6,7,8}                  code length (default 6)")
+		fmt.Println("    --period seconds                  time step length (default 30)")
+		fmt.Println("    --steam                           Steam Guard codes of five letters and digits")
+		fmt.Println("    --blizzard                        Battle.net Authenticator codes of eight digits")
+		fmt.Println("    --log-level {debug,info,warn,error}  diagnostics shown on stderr (default info)")
+		fmt.Println("    --log-format {text,json}          format of the diagnostics (default text)")
+		fmt.Println("    --config file                     TOML or YAML file with defaults for algorithm, digits, period, style, window and file")
+		fmt.Println("table styles (--style or GOOGAUTH_STYLE):")
+		fmt.Println("    0  plain columns")
+		fmt.Println("    1  simple, with a rule under the header")
+		fmt.Println("    2  grid with borders (default)")
+		fmt.Println("    3  GitHub-Flavored Markdown table")
+		return
+	}
+
+	cmd := args[1]
+	switch cmd {
+	case "-c", "--create":
+		runCreate(args[2:])
+	case "-v", "--verify":
+		runVerify(args[2:])
+	case "-d", "--display":
+		runDisplay(args[2:])
+	case "--hotp":
+		runHOTP(args[2:])
+	case "--hotp-verify":
+		runHOTPVerify(args[2:])
+	case "-l", "--list":
+		runList(args[2:])
+	case "-a", "--add":
+		runAdd(args[2:])
+	case "-r", "--remove":
+		runRemove(args[2:])
+	case "--rename":
+		runRename(args[2:])
+	case "--copy-entry":
+		runCopyEntry(args[2:])
+	case "--count":
+		runCount(args[2:])
+	case "--check-expiry":
+		runCheckExpiry(args[2:])
+	case "--list-recovery":
+		runListRecovery(args[2:])
+	case "--print-audit":
+		runPrintAudit(args[2:])
+	case "--diff":
+		runDiff(args[2:])
+	case "--merge":
+		runMerge(args[2:])
+	case "--validate-secret":
+		runValidateSecret(args[2:])
+	case "--export":
+		runExport(args[2:])
+	case "--import":
+		runImport(args[2:])
+	case "--save-toml", "--save-yaml":
+		runSave(cmd, args[2:])
+	case "--backup", "--restore":
+		runBackup(cmd, args[2:])
+	case "--daemon":
+		runDaemon(args[2:])
+	case "--client":
+		runClient(args[2:])
+	case "--serve":
+		runServe(args[2:])
+	case "--selftest":
+		if !selfTest() {
+			os.Exit(1)
+		}
+	case "--benchmark":
+		runBenchmark(args[2:])
+	case "--completion":
+		runCompletion(args[2:])
+	case "--version":
+		runVersion(args[2:])
+	case "--encrypt", "--decrypt":
+		runCrypt(cmd, args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, "unknown operation:", cmd)
+		os.Exit(2)
+	}
+}
+
+// quiet suppresses everything on stdout but the code for --display and
+// --verify, which then reports its result only through the exit status.
+var quiet bool
+
+// fatal prints its arguments to stderr and exits with status 1.
+func fatal(a ...any) {
+	fmt.Fprintln(os.Stderr, a...)
+	os.Exit(1)
+}
+
+// fatalf is like fatal but formats its arguments like fmt.Printf.
+func fatalf(format string, a ...any) {
+	fmt.Fprintf(os.Stderr, format, a...)
+	os.Exit(1)
+}
+
+func runCreate(args []string) {
+	cfg := gauth.DefaultConfig
+	showQR := true
+	output := ""
+	hotp := false
+	keyBits := 80
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	addConfigFlags(fs, &cfg)
+	fs.BoolVar(&showQR, "qr", showQR, "draw the otpauth:// URL as a QR code")
+	fs.StringVar(&output, "qr-output", "", "write the QR code to this PNG file instead of the terminal")
+	fs.StringVar(&output, "output", "", "alias for --qr-output")
+	fs.BoolVar(&hotp, "hotp", false, "create a counter-based account")
+	fs.IntVar(&keyBits, "key-bits", keyBits, "secret length in bits, a multiple of 5 of at least 80 (160 or more recommended)")
+	fs.StringVar(&cfg.Issuer, "issuer", "", "issuer shown by authenticator apps")
+	recoveryCount := 0
+	fs.IntVar(&recoveryCount, "recovery-codes", 0, "also generate this many one-time recovery codes, such as 10")
+	save := ""
+	fs.StringVar(&save, "save", "", "add the account, and its recovery codes, to this secrets file")
+	addDryRunFlag(fs)
+	var outputURI, outputSecret, outputBarcode bool
+	fs.BoolVar(&outputURI, "output-uri", false, "print only the otpauth:// URL")
+	fs.BoolVar(&outputSecret, "output-secret", false, "print only the secret")
+	fs.BoolVar(&outputBarcode, "output-barcode", false, "print only the QR code, or write it to --qr-output silently")
+	count := 1
+	fs.IntVar(&count, "count", count, "generate this many independent secrets, listed one per line or as JSON")
+	format := "list"
+	fs.StringVar(&format, "format", format, "with --count: list or json")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+	only := 0
+	for _, set := range []bool{outputURI, outputSecret, outputBarcode} {
+		if set {
+			only++
+		}
+	}
+	if only > 1 {
+		fatal("use only one of --output-uri, --output-secret and --output-barcode")
+	}
+	if only > 0 && (recoveryCount > 0 || save != "") {
+		fatal("--recovery-codes and --save can not be combined with --output-uri, --output-secret or --output-barcode")
+	}
+	if count < 1 {
+		fatal("count must be positive")
+	}
+	switch format {
+	case "list", "json":
+	default:
+		fatalf("unknown format: %s\n", format)
+	}
+
+	user := ""
+	domain := ""
+	if len(args) > 0 {
+		user = args[0]
+	}
+	if len(args) > 1 {
+		domain = args[1]
+	}
+	if save != "" && user == "" && domain == "" {
+		fatal("--save requires user or domain")
+	}
+	if count > 1 || format != "list" {
+		if recoveryCount > 0 || outputBarcode || save != "" {
+			fatal("--count and --format can not be combined with --recovery-codes, --save or --output-barcode")
+		}
+		if format == "json" && (outputURI || outputSecret) {
+			fatal("--format json can not be combined with --output-uri or --output-secret")
+		}
+		created, err := createSecrets(cfg, count, keyBits, hotp, user, domain, output)
+		if err == nil {
+			err = printSecrets(created, format, outputURI, outputSecret)
+		}
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	key, err := gauth.GenerateSecretKeyN(keyBits)
+	if err != nil {
+		fatal("can not generate secret:", err)
+	}
+	otpAuthURL := cfg.OTPAuthURL(user, domain, key)
+	if hotp {
+		otpAuthURL = cfg.HOTPAuthURL(user, domain, key, 0)
+	}
+	switch {
+	case outputURI:
+		fmt.Println(otpAuthURL)
+		return
+	case outputSecret:
+		fmt.Println(key)
+		return
+	case outputBarcode:
+		if output != "" {
+			if err := writeQRPNG(expandHome(output), otpAuthURL); err != nil {
+				fatal("can not write QR code:", err)
+			}
+			return
+		}
+		printQR(otpAuthURL)
+		return
+	}
+	var codes []string
+	if recoveryCount > 0 {
+		codes, err = gauth.GenerateRecoveryCodes(recoveryCount)
+		if err != nil {
+			fatal("can not generate recovery codes:", err)
+		}
+	}
+	if save != "" {
+		section := createdSection(cfg, user, domain, key, hotp)
+		if err := saveCreated(expandHome(save), section, codes); err != nil {
+			fatal(err)
+		}
+		if dryRun {
+			exitDryRun()
+		}
+		fmt.Println("added:", section.Name)
+	}
+	fmt.Println("secret:", key)
+	fmt.Println("url:", otpAuthURL)
+	if len(codes) > 0 {
+		fmt.Println("recovery codes:", strings.Join(codes, ", "))
+	}
+	showBarcode(otpAuthURL, showQR, output)
+}
+
+// createdSection returns the INI section of an account made by
+// --create, named user@domain like those of --add, with the parameters
+// of cfg that differ from the defaults.
+func createdSection(cfg gauth.Config, user, domain, secret string, hotp bool) newSection {
+	acct := fileAccount{Secret: secret, User: user, Domain: domain}
+	if cfg.Algorithm != gauth.DefaultConfig.Algorithm {
+		acct.Algorithm = string(cfg.Algorithm)
+	}
+	if cfg.Digits != gauth.DefaultConfig.Digits {
+		acct.Digits = cfg.Digits
+	}
+	if cfg.Format != "" && cfg.Format != gauth.Numeric {
+		acct.Format = string(cfg.Format)
+	}
+	if hotp {
+		acct.Type = "hotp"
+	} else if cfg.Period != gauth.DefaultConfig.Period {
+		acct.Period = cfg.Period
+	}
+	return newSection{Name: user + "@" + domain, Values: acct.section()}
+}
+
+func printQR(text string) {
+	code, err := renderQR(text)
+	if err != nil {
+		fatal("can not render QR code:", err)
+	}
+	fmt.Print(code)
+}
+
+// showBarcode writes the QR code of a new account's URL to output as a
+// PNG image, or draws it on the terminal when output is empty and
+// showQR is set. The code is never sent to an online service.
+func showBarcode(text string, showQR bool, output string) {
+	switch {
+	case output != "":
+		if err := writeQRPNG(expandHome(output), text); err != nil {
+			fatal("can not write QR code:", err)
+		}
+		fmt.Println("barcode:", output)
+	case showQR:
+		printQR(text)
+	}
+}
+
+func runVerify(args []string) {
+	cfg := gauth.DefaultConfig
+	clockDrift := false
+	strict := false
+	auditLog, label, stateFile := "", "", ""
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	addConfigFlags(fs, &cfg)
+	fs.BoolVar(&clockDrift, "clock-drift", false, "report when the code was generated by a clock out of sync")
+	fs.IntVar(&cfg.Window, "window", cfg.Window, "number of time steps before and after the current one to accept; larger windows make replayed codes more likely to pass")
+	fs.BoolVar(&strict, "strict", false, "only accept the code of the current time step, for clocks kept in sync")
+	fs.BoolVar(&quiet, "quiet", false, "print nothing, exit with status 0 on success and 1 on failure")
+	fs.StringVar(&auditLog, "audit-log", "", "append a JSON line recording the attempt to this file")
+	fs.StringVar(&label, "label", "", "account name recorded in the audit log and state file")
+	fs.StringVar(&stateFile, "state-file", "", "file recording the last time step accepted for each account to refuse replays (default used-codes.json in the config directory)")
+	var p pin
+	addPINFlags(fs, &p)
+	interactive := false
+	fs.BoolVar(&interactive, "interactive", false, "prompt for the code instead of taking it from the command line")
+	var at time.Time
+	addTimeFlag(fs, &at)
+	var src secretSource
+	addSecretFlags(fs, &src)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+
+	secret, args, err := src.resolve(args)
+	if err == nil {
+		secret, err = resolveOTPAuthURL(secret, &cfg, fs)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if args, err = codeArgs(args, interactive); err != nil {
+		fatal(err)
+	}
+	if len(args) < 1 {
+		fatal("require secret and code parameters")
+	}
+	code, hasPIN := p.strip(args[0])
+	if cfg.Window < 0 {
+		fatal("window must not be negative")
+	}
+	if strict {
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "window" {
+				fatal("--strict can not be combined with --window")
+			}
+		})
+	}
+	if cfg.Window > maxVerifyWindow && !quiet {
+		slog.Warn("large windows accept old codes", "window", cfg.Window, "max_age_seconds", cfg.Window*int(cfg.Period))
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	var offset int
+	var ok bool
+	started, step := time.Now(), cfg.TimeStep(at)
+	if !hasPIN {
+		slog.Debug("code lacks the PIN")
+	} else {
+		offset, ok, err = verifyCode(cfg, secret, code, strict, at)
+	}
+	slog.Debug("verified code", "ok", ok, "offset", offset, "duration", time.Since(started))
+	if ok {
+		if stateFile == "" {
+			if stateFile, err = defaultUsedCodesFile(); err != nil {
+				fatal("can not locate state file:", err)
+			}
+		}
+		store := usedCodesFile(expandHome(stateFile))
+		err = store.Accept(usedCodeKey(label, secret), step+uint64(offset))
+		if err != nil && !errors.Is(err, gauth.ErrCodeReused) {
+			fatal("can not update state file:", err)
+		}
+		ok = err == nil
+	}
+	if auditLog != "" {
+		record := newAuditRecord(time.Now(), label, offset, ok)
+		if err := appendAudit(expandHome(auditLog), record); err != nil {
+			fatal("can not write audit log:", err)
+		}
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if !ok {
+		if quiet {
+			os.Exit(1)
+		}
+		fatal("verification failed")
+	}
+	if quiet {
+		return
+	}
+	if clockDrift && offset != 0 {
+		fmt.Println(describeDrift(offset, cfg.Period))
+		return
+	}
+	fmt.Println("verification succeeded")
+}
+
+// codeArgs returns the parameters of --verify following the secret.
+// With --interactive there must be none, and the code is read through
+// readSecret instead.
+func codeArgs(args []string, interactive bool) ([]string, error) {
+	if !interactive {
+		return args, nil
+	}
+	if len(args) > 0 {
+		return nil, errors.New("--interactive can not be combined with a code parameter")
+	}
+	entered, err := readSecret("Enter code: ")
+	if err != nil {
+		return nil, fmt.Errorf("can not read code: %w", err)
+	}
+	return []string{strings.TrimSpace(entered)}, nil
+}
+
+// maxVerifyWindow is the largest --window of --verify accepted without
+// a warning.
+const maxVerifyWindow = 5
+
+// verifyCode checks code against the time steps around at like
+// VerifyTimeBasedAt or, when strict, against the step of at alone.
+func verifyCode(cfg gauth.Config, secret, code string, strict bool, at time.Time) (offset int, ok bool, err error) {
+	window := cfg.Window
+	if strict {
+		window = 0
+	}
+	slog.Debug("verifying code", "epoch", at.Unix(), "step", cfg.TimeStep(at),
+		"offsets", fmt.Sprintf("%d..%d", -window, window))
+	return cfg.VerifyTimeBasedAt(secret, code, window, at)
+}
+
+// describeDrift explains a non-zero time step offset returned by
+// VerifyTimeBased in terms of the clock that generated the code.
+func describeDrift(offset int, period uint) string {
+	direction := "ahead"
+	if offset < 0 {
+		direction = "behind"
+		offset = -offset
+	}
+	seconds := offset * int(period)
+	return fmt.Sprintf("code valid, but your clock appears to be ~%d seconds %s", seconds, direction)
+}
+
+func runDisplay(args []string) {
+	cfg := gauth.DefaultConfig
+	showQR := false
+	copyCode := false
+	clearOnExpire := false
+	fs := flag.NewFlagSet("display", flag.ContinueOnError)
+	addConfigFlags(fs, &cfg)
+	fs.BoolVar(&showQR, "qr", false, "draw the otpauth:// URL as a QR code")
+	fs.BoolVar(&copyCode, "copy", false, "copy the code to the clipboard")
+	fs.BoolVar(&clearOnExpire, "clear-on-expire", false, "clear the clipboard when the copied code expires")
+	watch := false
+	fs.BoolVar(&watch, "watch", false, "keep refreshing the code in place")
+	fs.BoolVar(&quiet, "quiet", false, "print only the code")
+	var p pin
+	addPINFlags(fs, &p)
+	var at time.Time
+	addTimeFlag(fs, &at)
+	var src secretSource
+	addSecretFlags(fs, &src)
+	fs.BoolVar(&src.prompt, "interactive", false, "prompt for the secret instead of taking it from the command line")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+
+	secret, _, err := src.resolve(args)
+	if err == nil {
+		secret, err = resolveOTPAuthURL(secret, &cfg, fs)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if watch {
+		if !at.IsZero() {
+			fatal("--watch can not be combined with --time")
+		}
+		if quiet {
+			fatal("--watch can not be combined with --quiet")
+		}
+		if err := watchCode(cfg, secret, p); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	code, err := cfg.GenerateTimeBasedAt(secret, at)
+	if err != nil {
+		fatal(err)
+	}
+	code = p.wrap(code)
+	fmt.Println(code)
+	if showQR && !quiet {
+		printQR(cfg.OTPAuthURL("", "", secret))
+	}
+	if copyCode {
+		copyCodeToClipboard(code, cfg.Expiry(at), clearOnExpire)
+	}
+}
+
+// copyCodeToClipboard copies code to the clipboard and, when asked to,
+// waits until expiry to clear it again.
+func copyCodeToClipboard(code string, expiry time.Time, clearOnExpire bool) {
+	if err := copyToClipboard(code); err != nil {
+		fatal("can not copy code:", err)
+	}
+	if !quiet {
+		fmt.Println("code copied to clipboard")
+	}
+	if !clearOnExpire {
+		return
+	}
+	time.Sleep(time.Until(expiry))
+	if err := copyToClipboard(""); err != nil {
+		fatal("can not clear clipboard:", err)
+	}
+	if !quiet {
+		fmt.Println("clipboard cleared")
+	}
+}
+
+func runHOTP(args []string) {
+	cfg := gauth.DefaultConfig
+	fs := flag.NewFlagSet("hotp", flag.ContinueOnError)
+	addConfigFlags(fs, &cfg)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+
+	if len(args) < 2 {
+		fatal("require secret and counter parameters")
+	}
+	counter, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		fatal("invalid counter:", args[1])
+	}
+	code, err := cfg.GenerateCode(args[0], counter)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(code)
+}
+
+func runHOTPVerify(args []string) {
+	cfg := gauth.DefaultConfig
+	window := 3
+	fs := flag.NewFlagSet("hotp-verify", flag.ContinueOnError)
+	addConfigFlags(fs, &cfg)
+	fs.IntVar(&window, "window", window, "number of counter values after counter to check")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+
+	if len(args) < 3 {
+		fatal("require secret, code and counter parameters")
+	}
+	counter, err := strconv.Atoi(args[2])
+	if err != nil || counter < 0 {
+		fatal("invalid counter:", args[2])
+	}
+	next, err := cfg.VerifyCounterBased(args[0], args[1], counter, window)
+	if err != nil {
+		fatal(err)
+	}
+	if next == -1 {
+		fatal("verification failed")
+	}
+	fmt.Println("verification succeeded")
+	fmt.Println("counter:", next)
+}
+
+func runList(args []string) {
+	cfg := gauth.DefaultConfig
+	opts := listOptions{Format: "table", Style: tableStyle(), Interval: time.Second}
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	addConfigFlags(fs, &cfg)
+	fs.BoolVar(&opts.Continue, "continue", false, "keep refreshing the codes until interrupted")
+	fs.BoolVar(&opts.Continue, "c", false, "shorthand for --continue")
+	fs.BoolVar(&opts.Once, "once", false, "with --continue, exit after the codes have refreshed once")
+	fs.BoolVar(&opts.Align, "align-refresh", false, "with --continue, refresh exactly when the codes change instead of every --interval")
+	fs.DurationVar(&opts.Interval, "interval", opts.Interval, "with --continue, the time between refreshes, such as 500ms or 2s")
+	fs.BoolVar(&opts.Next, "next", false, "also show the code of the next time step")
+	fs.BoolVar(&opts.Prev, "prev", false, "also show the code of the previous time step")
+	fs.StringVar(&opts.Format, "format", opts.Format, "output format: table, json or csv")
+	var rowTemplate string
+	fs.StringVar(&rowTemplate, "template", "", "print each account with this text/template, such as '{{.User}}:{{.Code}}'")
+	fs.BoolVar(&opts.Stamp, "timestamp", false, "with --format csv, add a timestamp column and, with --continue, write the header only once")
+	fs.StringVar(&opts.Style, "style", opts.Style, "table style: 0=plain, 1=simple, 2=grid, 3=markdown (overrides GOOGAUTH_STYLE)")
+	sortBy := "name"
+	fs.StringVar(&sortBy, "sort", sortBy, "sort accounts by name, user, domain or none (file order)")
+	var copySection, search string
+	clearOnExpire := false
+	fs.StringVar(&search, "search", "", "only list accounts whose profile, user or domain contains this text or matches this glob")
+	fs.StringVar(&copySection, "copy", "", "copy the code of this section to the clipboard")
+	fs.BoolVar(&clearOnExpire, "clear-on-expire", false, "clear the clipboard when the copied code expires")
+	useKeychain := false
+	fs.BoolVar(&useKeychain, "keychain", false, "also list the secrets stored in the OS keychain")
+	var dir string
+	fs.StringVar(&dir, "config-dir", "", "directory of the default "+secretsFileName+" used without a file name")
+	watchFile := false
+	fs.BoolVar(&watchFile, "watch-file", false, "with --continue, reload the accounts when the file changes")
+	noTUI := false
+	fs.BoolVar(&noTUI, "no-tui", false, "with --continue, print the table over and over instead of updating it in place")
+	interactive := false
+	fs.BoolVar(&interactive, "interactive", false, "offer to advance the counters of HOTP accounts, and read the passphrase of an encrypted file from the terminal only")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+	terminalOnly = interactive
+	switch opts.Format {
+	case "table", "json", "csv":
+	default:
+		fatalf("unknown format: %s\n", opts.Format)
+	}
+	if rowTemplate != "" {
+		if opts.Format != "table" {
+			fatal("--template can not be combined with --format")
+		}
+		if opts.Template, err = template.New("row").Parse(rowTemplate); err != nil {
+			fatal("invalid template:", err)
+		}
+		opts.Format = "template"
+	}
+	switch opts.Style {
+	case "0", "1", "2", "3":
+	default:
+		fatalf("unknown style: %s\n", opts.Style)
+	}
+	switch sortBy {
+	case "name", "user", "domain", "none":
+	default:
+		fatalf("unknown sort order: %s\n", sortBy)
+	}
+
+	var filename string
+	if len(args) > 0 {
+		filename = expandHome(args[0])
+	} else if cliConfig.File != "" && dir == "" {
+		filename = expandHome(cliConfig.File)
+	} else if filename, err = defaultSecretsFile(dir); err != nil {
+		fatal("can not find the default secrets file:", err)
+	}
+	if len(args) > 1 && args[1] == "-" {
+		opts.Continue = true
+	}
+	if opts.Stamp && opts.Format != "csv" {
+		fatal("--timestamp requires --format csv")
+	}
+	if opts.Once && !opts.Continue {
+		fatal("--once requires --continue")
+	}
+	if opts.Interval < 100*time.Millisecond {
+		fatal("--interval must be at least 100ms")
+	}
+	if watchFile && !opts.Continue {
+		fatal("--watch-file requires --continue")
+	}
+	load := func() ([]account, map[string]map[string]string, error) {
+		config, order, err := loadConfig(filename)
+		if useKeychain && errors.Is(err, gauth.ErrFileNotFound) {
+			config, err = make(map[string]map[string]string), nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if useKeychain {
+			if err := mergeKeychain(config); errors.Is(err, errNoKeychain) {
+				slog.Warn("listing the secrets file only", "file", filename, "error", err)
+			} else if err != nil {
+				return nil, nil, err
+			}
+		}
+		table, err := newAccounts(cfg, config)
+		if err != nil {
+			return nil, nil, err
+		}
+		sortAccounts(table, sortBy, order)
+		if search != "" {
+			table = filterAccounts(table, search)
+		}
+		return table, config, nil
+	}
+	table, config, err := load()
+	if err != nil {
+		fatal(err)
+	}
+	if watchFile {
+		opts.Reload = func() ([]account, error) {
+			table, _, err := load()
+			return table, err
+		}
+		opts.Watch = filename
+	}
+	if copySection != "" {
+		name, ok := findSection(config, copySection)
+		if !ok {
+			fatalf("section [%s] not found in %s\n", copySection, filename)
+		}
+		for _, acct := range table {
+			if acct.Name != name {
+				continue
+			}
+			now := time.Now()
+			code, err := acct.code(now)
+			if err != nil {
+				fatal(err)
+			}
+			// Clearing blocks until expiry, so it waits for the listing.
+			if clearOnExpire && !opts.Continue && acct.Type == "totp" {
+				defer copyCodeToClipboard(code, acct.Config.Expiry(now), true)
+			} else {
+				copyCodeToClipboard(code, time.Time{}, false)
+			}
+		}
+	}
+	if useTUI(opts) && !noTUI {
+		if err := listTUI(table, opts); err != nil {
+			fatal(err)
+		}
+	} else if status := listCode(table, opts, os.Stdout); status != 0 {
+		os.Exit(status)
+	}
+	if interactive && !opts.Continue && checkWritable(filename) == nil {
+		if err := advanceCounters(filename, table); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+func runAdd(args []string) {
+	cfg := gauth.DefaultConfig
+	var user, domain, profile, secret string
+	showQR := true
+	output := ""
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	fs.StringVar(&user, "user", "", "account user name")
+	fs.StringVar(&domain, "domain", "", "account domain")
+	fs.StringVar(&profile, "profile", "", "section name (default user@domain)")
+	fs.StringVar(&secret, "secret", "", "base32 secret (generated when omitted)")
+	fs.BoolVar(&showQR, "qr", showQR, "draw the otpauth:// URL as a QR code")
+	fs.StringVar(&output, "qr-output", "", "write the QR code to this PNG file instead of the terminal")
+	fs.StringVar(&output, "output", "", "alias for --qr-output")
+	useKeychain := false
+	fs.BoolVar(&useKeychain, "keychain", false, "store the secret in the OS keychain instead of the file")
+	addDryRunFlag(fs)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+
+	if len(args) < 1 {
+		fatal("require file name")
+	}
+	if user == "" && domain == "" {
+		fatal("require user or domain")
+	}
+	for _, value := range []string{user, domain} {
+		if value != "" && !validName(value) {
+			fatalf("invalid user or domain %q\n", value)
+		}
+	}
+	if profile != "" && !validName(profile) {
+		fatalf("invalid --profile %q\n", profile)
+	}
+	filename := expandHome(args[0])
+	if secret == "" {
+		secret, err = gauth.GenerateSecretKey()
+		if err != nil {
+			fatal("can not generate secret:", err)
+		}
+	}
+	if _, err := cfg.GenerateCode(secret, 0); err != nil {
+		fatal(err)
+	}
+
+	name := profile
+	if name == "" {
+		name = user + "@" + domain
+	}
+	values := map[string]string{
+		"secret": secret,
+		"user":   user,
+		"domain": domain,
+	}
+	if useKeychain {
+		if !keychainSupported() {
+			slog.Warn("storing the secret in the secrets file", "file", filename, "error", errNoKeychain)
+		} else {
+			delete(values, "secret")
+		}
+	}
+	if err := addSection(filename, name, values); err != nil {
+		fatal(err)
+	}
+	if dryRun {
+		if values["secret"] == "" {
+			fmt.Println("would store secret in keychain:", name)
+		}
+		exitDryRun()
+	}
+	if values["secret"] == "" {
+		if err := keychainSet(name, secret); err != nil {
+			removeSection(filename, name)
+			fatal("can not store secret in keychain:", err)
+		}
+	}
+
+	fmt.Println("added:", name)
+	otpAuthURL := cfg.OTPAuthURL(user, domain, secret)
+	fmt.Println("url:", otpAuthURL)
+	showBarcode(otpAuthURL, showQR, output)
+}
+
+func runRename(args []string) {
+	fs := flag.NewFlagSet("rename", flag.ContinueOnError)
+	addDryRunFlag(fs)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+
+	if len(args) < 3 {
+		fatal("require file name, section and new section name")
+	}
+	oldName, err := renameSection(expandHome(args[0]), args[1], args[2])
+	if err != nil {
+		fatal(err)
+	}
+	if dryRun {
+		exitDryRun()
+	}
+	fmt.Printf("renamed: %s -> %s\n", oldName, args[2])
+}
+
+func runCopyEntry(args []string) {
+	force := false
+	fs := flag.NewFlagSet("copy-entry", flag.ContinueOnError)
+	fs.BoolVar(&force, "force", false, "overwrite the new section when it exists")
+	addDryRunFlag(fs)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+
+	if len(args) < 3 {
+		fatal("require file name, section and new section name")
+	}
+	name, err := copySection(expandHome(args[0]), args[1], args[2], force)
+	if err != nil {
+		fatal(err)
+	}
+	if dryRun {
+		exitDryRun()
+	}
+	fmt.Printf("copied: %s -> %s\n", name, args[2])
+}
+
+func runRemove(args []string) {
+	useKeychain := false
+	fs := flag.NewFlagSet("remove", flag.ContinueOnError)
+	fs.BoolVar(&useKeychain, "keychain", false, "also delete the secret stored in the OS keychain")
+	addDryRunFlag(fs)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+
+	if len(args) < 2 {
+		fatal("require file name and section")
+	}
+	filename := expandHome(args[0])
+	name, err := removeSection(filename, args[1])
+	if useKeychain {
+		// The account may live in the keychain only.
+		item := name
+		if err != nil {
+			item = args[1]
+		}
+		if dryRun {
+			if _, kerr := keychainGet(item); kerr == nil {
+				fmt.Println("would remove from keychain:", item)
+				dryRunChanged = true
+				if err != nil {
+					exitDryRun()
+				}
+			}
+		} else if kerr := keychainDelete(item); kerr == nil {
+			fmt.Println("removed from keychain:", item)
+			if err != nil {
+				return
+			}
+		}
+	}
+	if err != nil {
+		fatal(err)
+	}
+	if dryRun {
+		exitDryRun()
+	}
+	fmt.Println("removed:", name)
+}
+
+func runBackup(cmd string, args []string) {
+	fs := flag.NewFlagSet(strings.TrimPrefix(cmd, "--"), flag.ContinueOnError)
+	addDryRunFlag(fs)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+
+	if len(args) < 2 {
+		fatal("require two file names")
+	}
+	from, to := expandHome(args[0]), expandHome(args[1])
+	if cmd == "--backup" {
+		if err := backupFile(from, to); err != nil {
+			fatal(err)
+		}
+		if dryRun {
+			exitDryRun()
+		}
+		fmt.Println("backed up:", to)
+		return
+	}
+	if err := restoreFile(from, to); err != nil {
+		fatal(err)
+	}
+	if dryRun {
+		exitDryRun()
+	}
+	fmt.Println("restored:", to)
+}
+
+func runDaemon(args []string) {
+	cfg := gauth.DefaultConfig
+	socket := defaultSocket()
+	var filename string
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	addConfigFlags(fs, &cfg)
+	fs.StringVar(&socket, "socket", socket, "path of the Unix socket to listen on")
+	fs.StringVar(&filename, "file", "", "secrets file to serve codes from")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+
+	if filename == "" && len(args) > 0 {
+		filename = args[0]
+	}
+	if filename == "" {
+		fatal("require file name")
+	}
+	config, _, err := loadConfig(expandHome(filename))
+	if err != nil {
+		fatal(err)
+	}
+	table, err := newAccounts(cfg, config)
+	if err != nil {
+		fatal(err)
+	}
+	slog.Info("listening", "socket", socket)
+	if err := serveSocket(expandHome(socket), table); err != nil {
+		fatal(err)
+	}
+}
+
+func runClient(args []string) {
+	socket := defaultSocket()
+	fs := flag.NewFlagSet("client", flag.ContinueOnError)
+	fs.StringVar(&socket, "socket", socket, "path of the daemon's Unix socket")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+
+	if len(args) < 1 {
+		fatal("require account name")
+	}
+	resp, err := queryDaemon(expandHome(socket), args[0])
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(resp.Code)
+}
+
+func runServe(args []string) {
+	cfg := gauth.DefaultConfig
+	addr := "127.0.0.1:8080"
+	var filename, token, certFile, keyFile string
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addConfigFlags(fs, &cfg)
+	fs.StringVar(&addr, "addr", addr, "address to listen on")
+	fs.StringVar(&filename, "file", "", "secrets file to serve codes from")
+	fs.StringVar(&token, "token", "", "bearer token clients must send")
+	fs.StringVar(&certFile, "tls-cert", "", "TLS certificate file")
+	fs.StringVar(&keyFile, "tls-key", "", "TLS private key file")
+	timeout := 200 * time.Millisecond
+	fs.DurationVar(&timeout, "request-timeout", timeout, "longest time POST /verify may take")
+	metrics := false
+	fs.BoolVar(&metrics, "metrics", false, "serve Prometheus metrics at GET /metrics, to clients with the token")
+	scim := false
+	fs.BoolVar(&scim, "scim", false, "let clients with the token add and remove accounts at /scim/v2/TOTPFactors")
+	stateFile := ""
+	fs.StringVar(&stateFile, "state-file", "", "file recording the last time step accepted for each account to refuse replays (default used-codes.json in the config directory)")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+
+	if filename == "" && len(args) > 0 {
+		filename = args[0]
+	}
+	if filename == "" {
+		fatal("require file name")
+	}
+	if token == "" {
+		fatal("require --token")
+	}
+	if timeout <= 0 {
+		fatal("--request-timeout must be positive")
+	}
+	if (certFile == "") != (keyFile == "") {
+		fatal("require both --tls-cert and --tls-key")
+	}
+	if certFile == "" && !isLoopback(addr) {
+		slog.Warn("serving codes without TLS", "addr", addr)
+	}
+	filename = expandHome(filename)
+	config, _, err := loadConfig(filename)
+	if err != nil {
+		fatal(err)
+	}
+	table, err := newAccounts(cfg, config)
+	if err != nil {
+		fatal(err)
+	}
+	if stateFile == "" {
+		if stateFile, err = defaultUsedCodesFile(); err != nil {
+			fatal("can not locate state file:", err)
+		}
+	}
+
+	handler := newHTTPHandler(table, serveOptions{
+		Filename: filename,
+		Token:    token,
+		Timeout:  timeout,
+		Metrics:  metrics,
+		SCIM:     scim,
+		Config:   cfg,
+		Replay:   usedCodesFile(expandHome(stateFile)),
+	})
+	server := newHTTPServer(addr, handler, timeout)
+	slog.Info("listening", "addr", addr)
+	if certFile != "" {
+		err = server.ListenAndServeTLS(expandHome(certFile), expandHome(keyFile))
+	} else {
+		err = server.ListenAndServe()
+	}
+	fatal(err)
+}
+
+func runBenchmark(args []string) {
+	iterations := 100000
+	style := tableStyle()
+	fs := flag.NewFlagSet("benchmark", flag.ContinueOnError)
+	fs.IntVar(&iterations, "iterations", iterations, "number of codes generated per algorithm")
+	fs.StringVar(&style, "style", style, "table style: 0=plain, 1=simple, 2=grid, 3=markdown")
+	if _, err := parseArgs(fs, args); err != nil {
+		exitParseError(err)
+	}
+	if iterations <= 0 {
+		fatal("iterations must be positive")
+	}
+
+	secret, err := gauth.GenerateSecretKey()
+	if err != nil {
+		fatal("can not generate secret:", err)
+	}
+	results, err := benchmark(secret, iterations)
+	if err != nil {
+		fatal(err)
+	}
+	align := []alignment{alignLeft, alignRight, alignRight, alignRight}
+	fmt.Println(tabulify(benchmarkTable(results), style, align, 0))
+}
+
+func runCompletion(args []string) {
+	if len(args) < 1 {
+		fatal("require shell name: bash, zsh or fish")
+	}
+	script, err := completionScript(args[0])
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Print(script)
+}
+
+func runVersion(args []string) {
+	format := "text"
+	fs := flag.NewFlagSet("version", flag.ContinueOnError)
+	fs.StringVar(&format, "format", format, "output format: text or json")
+	if _, err := parseArgs(fs, args); err != nil {
+		exitParseError(err)
+	}
+
+	info := currentVersion()
+	switch format {
+	case "text":
+		fmt.Println("gauth", info.Version)
+		if info.Commit != "" {
+			fmt.Println("commit:", info.Commit)
+		}
+		if info.BuildDate != "" {
+			fmt.Println("built:", info.BuildDate)
+		}
+		fmt.Println("go:", info.GoVersion)
+	case "json":
+		json.NewEncoder(os.Stdout).Encode(info)
+	default:
+		fatalf("unknown format: %s\n", format)
+	}
+}
+
+func runCrypt(cmd string, args []string) {
+	if len(args) < 1 {
+		fatal("require file name")
+	}
+	filename := expandHome(args[0])
+	var err error
+	if cmd == "--encrypt" {
+		err = encryptFile(filename)
+	} else {
+		err = decryptFile(filename)
+	}
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Printf("%sed: %s\n", strings.TrimPrefix(cmd, "--"), filename)
+}
+
+// runSave converts a secrets file to the format named by cmd, keeping
+// the encryption of the original file.
+func runSave(cmd string, args []string) {
+	if len(args) < 1 {
+		fatal("require file name")
+	}
+	format := strings.TrimPrefix(cmd, "--save-")
+	filename := expandHome(args[0])
+	output := strings.TrimSuffix(filename, filepath.Ext(filename)) + "." + format
+	if len(args) > 1 {
+		output = expandHome(args[1])
+	}
+	if _, err := os.Stat(output); err == nil {
+		fatalf("%s already exists\n", output)
+	}
+
+	config, _, err := loadConfig(filename)
+	if err != nil {
+		fatal(err)
+	}
+	content, err := encodeConfig(format, config)
+	if err != nil {
+		fatal(err)
+	}
+	if err := writeSecretsFile(output, content, passphrases[filename]); err != nil {
+		fatal(err)
+	}
+	fmt.Println("saved:", output)
+}
+
+func runExport(args []string) {
+	cfg := gauth.DefaultConfig
+	showQR := false
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	addConfigFlags(fs, &cfg)
+	fs.BoolVar(&showQR, "qr", false, "draw the otpauth-migration:// URL as a QR code")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+
+	if len(args) < 1 {
+		fatal("require file name")
+	}
+	config, _, err := loadConfig(expandHome(args[0]))
+	if err != nil {
+		fatal(err)
+	}
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	accounts := make([]gauth.MigrationAccount, 0, len(keys))
+	for _, key := range keys {
+		acct, err := newAccount(cfg, key, config[key])
+		if err != nil {
+			fatalf("invalid entry [%s]: %v\n", key, err)
+		}
+		if acct.Type == "totp" && acct.Config.Period != gauth.DefaultConfig.Period {
+			fatalf("can not export [%s]: period must be %d seconds\n", key, gauth.DefaultConfig.Period)
+		}
+		name := key
+		if acct.User != "" || acct.Domain != "" {
+			name = acct.User + "@" + acct.Domain
+		}
+		accounts = append(accounts, gauth.MigrationAccount{
+			Secret:    acct.Secret,
+			Name:      name,
+			Issuer:    acct.Config.Issuer,
+			Algorithm: acct.Config.Algorithm,
+			Digits:    acct.Config.Digits,
+			Type:      acct.Type,
+			Counter:   acct.Counter,
+		})
+	}
+
+	migrationURL, err := gauth.MigrationURL(accounts)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(migrationURL)
+	if showQR {
+		printQR(migrationURL)
+	}
+}
+
+func runImport(args []string) {
+	var migrationURL string
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.StringVar(&migrationURL, "url", "", "otpauth-migration:// or otpauth:// URL (read from stdin when omitted)")
+	addDryRunFlag(fs)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+
+	if len(args) < 1 {
+		fatal("require file name")
+	}
+	filename := expandHome(args[0])
+
+	urls := []string{migrationURL}
+	if migrationURL == "" {
+		urls = nil
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				urls = append(urls, line)
+			}
+		}
+	}
+	if len(urls) == 0 {
+		fatal("require otpauth-migration:// or otpauth:// URL")
+	}
+
+	var sections []newSection
+	for _, u := range urls {
+		if strings.HasPrefix(u, "otpauth://") {
+			otp, err := gauth.ParseOTPAuthURL(u)
+			if err != nil {
+				fatal(err)
+			}
+			sections = append(sections, otpAuthSection(otp))
+			continue
+		}
+		accounts, err := gauth.ParseMigrationURL(u)
+		if err != nil {
+			fatal(err)
+		}
+		for _, acct := range accounts {
+			sections = append(sections, migrationSection(acct))
+		}
+	}
+
+	skipped, err := addSections(filename, sections, true)
+	if err != nil {
+		fatal(err)
+	}
+	if dryRun {
+		exitDryRun()
+	}
+	for _, section := range sections {
+		if slices.Contains(skipped, section.Name) {
+			fmt.Println("skipped (already exists):", section.Name)
+		} else {
+			fmt.Println("imported:", section.Name)
+		}
+	}
+}
+
+// migrationSection converts an imported account to an INI section
+// named user@domain like the ones written by --add.
+func migrationSection(acct gauth.MigrationAccount) newSection {
+	name := acct.Name
+	if i := strings.Index(name, ":"); i >= 0 {
+		name = strings.TrimSpace(name[i+1:]) // "Issuer:account" labels
+	}
+	user, domain := name, acct.Issuer
+	if i := strings.LastIndex(name, "@"); i >= 0 {
+		user, domain = name[:i], name[i+1:]
+	}
+
+	values := map[string]string{
+		"secret": acct.Secret,
+		"user":   user,
+		"domain": domain,
+	}
+	if acct.Algorithm != gauth.SHA1 {
+		values["algorithm"] = string(acct.Algorithm)
+	}
+	if acct.Digits != gauth.DefaultConfig.Digits {
+		values["digits"] = strconv.Itoa(acct.Digits)
+	}
+	if acct.Type == "hotp" {
+		values["type"] = acct.Type
+		values["counter"] = strconv.FormatUint(acct.Counter, 10)
+	}
+	return newSection{Name: user + "@" + domain, Values: values}
+}
+
+// otpAuthSection converts an account imported from an otpauth:// URL to
+// an INI section like migrationSection does.
+func otpAuthSection(otp *gauth.OTPConfig) newSection {
+	section := migrationSection(gauth.MigrationAccount{
+		Secret:    otp.Secret,
+		Name:      otp.Label,
+		Issuer:    otp.Issuer,
+		Algorithm: otp.Algorithm,
+		Digits:    otp.Digits,
+		Type:      otp.Type,
+		Counter:   otp.Counter,
+	})
+	if otp.Type == "totp" && otp.Period != gauth.DefaultConfig.Period {
+		section.Values["period"] = strconv.FormatUint(uint64(otp.Period), 10)
+	}
+	return section
+}
+
+// runCount prints the number of accounts, sections with a secret, as a
+// plain integer for use in scripts.
+func runCount(args []string) {
+	if len(args) < 1 {
+		fatal("require file name")
+	}
+	config, _, err := loadConfig(expandHome(args[0]))
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(countAccounts(config))
+}
+
+// countAccounts returns the number of sections of config with a secret.
+func countAccounts(config map[string]map[string]string) int {
+	count := 0
+	for _, section := range config {
+		if section["secret"] != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// runCheckExpiry warns about HOTP counters close to overflowing or left
+// unchanged for long, exiting with status 1 when there is a warning.
+func runCheckExpiry(args []string) {
+	if len(args) < 1 {
+		fatal("require file name")
+	}
+	config, _, err := loadConfig(expandHome(args[0]))
+	if err != nil {
+		fatal(err)
+	}
+	warnings := checkCounters(config, time.Now())
+	for _, warning := range warnings {
+		fmt.Println("warning:", warning)
+	}
+	if len(warnings) > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("no problems found")
+}
+
+// runDiff compares the accounts of two secrets files, printing "+" for
+// accounts only in the second, "-" for those only in the first and "~"
+// for those that differ. Like diff(1) it exits with status 1 when the
+// files differ.
+func runDiff(args []string) {
+	if len(args) < 2 {
+		fatal("require two file names")
+	}
+	var accounts [2]map[string]fileAccount
+	for i, filename := range args[:2] {
+		config, _, err := loadConfig(expandHome(filename))
+		if err != nil {
+			fatal(err)
+		}
+		if accounts[i], err = fileAccounts(config); err != nil {
+			fatalf("%s: %v\n", filename, err)
+		}
+	}
+	changes := compareAccounts(accounts[0], accounts[1])
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if len(changes) > 0 {
+		os.Exit(1)
+	}
+}
+
+// runMerge writes the sections of two INI files to a third, resolving
+// sections found in both with different keys by --on-conflict.
+func runMerge(args []string) {
+	output, onConflict := "", "error"
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	fs.StringVar(&output, "output", "", "file to write the merged sections to")
+	fs.StringVar(&onConflict, "on-conflict", onConflict, "for sections in both files that differ: keep-base, keep-overlay, error or prompt")
+	addDryRunFlag(fs)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+	if len(args) < 2 {
+		fatal("require base and overlay file names")
+	}
+	if output == "" {
+		fatal("require --output")
+	}
+	resolve, ok := conflictResolvers[onConflict]
+	if !ok {
+		fatalf("unknown conflict resolution: %s\n", onConflict)
+	}
+
+	base, overlay, output := expandHome(args[0]), expandHome(args[1]), expandHome(output)
+	for _, filename := range []string{base, overlay, output} {
+		if err := checkWritable(filename); err != nil {
+			fatal(err)
+		}
+	}
+	doc, err := loadINI(base)
+	if err != nil {
+		fatal(err)
+	}
+	overlayDoc, err := loadINI(overlay)
+	if err != nil {
+		fatal(err)
+	}
+	if err := mergeINI(doc, overlayDoc, resolve); err != nil {
+		fatal(err)
+	}
+	if err := writeSecretsFile(output, []byte(doc.String()), passphrases[base]); err != nil {
+		fatal(err)
+	}
+	if dryRun {
+		exitDryRun()
+	}
+	fmt.Println("merged:", output)
+}
+
+// runListRecovery prints the recovery codes stored in the
+// [recovery-codes] section of an INI file.
+func runListRecovery(args []string) {
+	if len(args) < 1 {
+		fatal("require file name")
+	}
+	codes, err := recoveryCodes(expandHome(args[0]))
+	if err != nil {
+		fatal(err)
+	}
+	name := ""
+	if len(args) > 1 {
+		name = args[1]
+	}
+	if err := printRecoveryCodes(codes, name); err != nil {
+		fatal(err)
+	}
+}
+
+// runPrintAudit shows the records of an --audit-log file as a table.
+func runPrintAudit(args []string) {
+	style := tableStyle()
+	fs := flag.NewFlagSet("print-audit", flag.ContinueOnError)
+	fs.StringVar(&style, "style", style, "table style: 0=plain, 1=simple, 2=grid, 3=markdown")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		exitParseError(err)
+	}
+	if len(args) < 1 {
+		fatal("require file name")
+	}
+	records, err := readAudit(expandHome(args[0]))
+	if err != nil {
+		fatal(err)
+	}
+	align := []alignment{alignLeft, alignLeft, alignLeft, alignRight, alignLeft}
+	fmt.Println(tabulify(auditTable(records), style, align, terminalWidth(os.Stdout)))
+}
+
+func runValidateSecret(args []string) {
+	if len(args) < 1 {
+		fatal("require secret parameter")
+	}
+	normalized, err := gauth.NormalizeSecret(strings.Join(args, " "))
+	if err != nil {
+		fmt.Println("invalid:", err)
+		os.Exit(1)
+	}
+	fmt.Println("valid:", normalized)
+}
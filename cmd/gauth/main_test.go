@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"gauth"
+)
+
+func TestDescribeDrift(t *testing.T) {
+	tests := []struct {
+		offset int
+		period uint
+		want   string
+	}{
+		{1, 30, "code valid, but your clock appears to be ~30 seconds ahead"},
+		{-1, 30, "code valid, but your clock appears to be ~30 seconds behind"},
+		{2, 30, "code valid, but your clock appears to be ~60 seconds ahead"},
+		{-2, 60, "code valid, but your clock appears to be ~120 seconds behind"},
+	}
+	for _, test := range tests {
+		if got := describeDrift(test.offset, test.period); got != test.want {
+			t.Errorf("describeDrift(%d, %d) = %q, want %q", test.offset, test.period, got, test.want)
+		}
+	}
+}
+
+func TestCountAccounts(t *testing.T) {
+	filename := writeTemp(t, "secrets.ini", "; no accounts yet\n")
+	tests := []struct {
+		content string
+		want    int
+	}{
+		{"; no accounts yet\n", 0},
+		{"[github]\nsecret = JBSWY3DPEHPK3PXP\n[work]\nsecret = GEZDGNBVGY3TQOJQ\n", 2},
+		{"[github]\nsecret = JBSWY3DPEHPK3PXP\n[draft]\nuser = alice\n[empty]\nsecret =\n", 1},
+	}
+	for _, test := range tests {
+		if err := os.WriteFile(filename, []byte(test.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		config, _, err := loadConfig(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := countAccounts(config); got != test.want {
+			t.Errorf("countAccounts of %q = %d, want %d", test.content, got, test.want)
+		}
+	}
+}
+
+// TestVerifyCodeStrict checks the codes of the steps either side of a
+// step boundary, at its last second and at the first of the next step.
+func TestVerifyCodeStrict(t *testing.T) {
+	cfg := gauth.Config{Digits: 8, Window: 1}
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	step1, step2 := "94287082", "37359152" // codes of the steps 30-59 and 60-89
+	tests := []struct {
+		at     int64
+		code   string
+		strict bool
+		offset int
+		ok     bool
+	}{
+		{59, step1, true, 0, true},
+		{59, step1, false, 0, true},
+		{59, step2, true, 0, false},
+		{59, step2, false, 1, true},
+		{60, step1, true, 0, false},
+		{60, step1, false, -1, true},
+		{60, step2, true, 0, true},
+		{60, step2, false, 0, true},
+	}
+	for _, test := range tests {
+		offset, ok, err := verifyCode(cfg, secret, test.code, test.strict, time.Unix(test.at, 0))
+		if err != nil || ok != test.ok || offset != test.offset {
+			t.Errorf("strict %v, code %s at %d: %d, %v, %v, want %d, %v",
+				test.strict, test.code, test.at, offset, ok, err, test.offset, test.ok)
+		}
+	}
+
+	for _, strict := range []bool{true, false} {
+		for _, code := range []string{"9428708", "942870820", "9428708x", ""} {
+			if _, ok, err := verifyCode(cfg, secret, code, strict, time.Unix(59, 0)); ok || !errors.Is(err, gauth.ErrInvalidCode) {
+				t.Errorf("strict %v, code %q: %v, %v, want ErrInvalidCode", strict, code, ok, err)
+			}
+		}
+	}
+}
+
+func TestVerifyInteractive(t *testing.T) {
+	var prompts []string
+	saved := readSecret
+	t.Cleanup(func() { readSecret = saved })
+	readSecret = func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		return " 94287082\r\n", nil
+	}
+
+	args, err := codeArgs(nil, true)
+	if err != nil || !slices.Equal(args, []string{"94287082"}) {
+		t.Fatalf("codeArgs = %q, %v, want the code entered", args, err)
+	}
+	if len(prompts) != 1 || prompts[0] != "Enter code: " {
+		t.Errorf("prompts %q, want one for the code", prompts)
+	}
+	cfg := gauth.Config{Digits: 8, Window: 1}
+	if _, ok, err := verifyCode(cfg, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", args[0], false, time.Unix(59, 0)); !ok || err != nil {
+		t.Errorf("code entered not accepted: %v, %v", ok, err)
+	}
+
+	if _, err := codeArgs([]string{"94287082"}, true); err == nil {
+		t.Error("--interactive with a code parameter succeeded")
+	}
+	if args, err := codeArgs([]string{"94287082"}, false); err != nil || !slices.Equal(args, []string{"94287082"}) {
+		t.Errorf("codeArgs without --interactive = %q, %v", args, err)
+	}
+	if len(prompts) != 1 {
+		t.Errorf("prompts %q, want none without --interactive", prompts[1:])
+	}
+
+	errTerminal := errors.New("no terminal")
+	readSecret = func(string) (string, error) { return "", errTerminal }
+	if _, err := codeArgs(nil, true); !errors.Is(err, errTerminal) {
+		t.Errorf("codeArgs with a failing read: %v, want %v", err, errTerminal)
+	}
+}
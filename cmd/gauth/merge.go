@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strings"
+)
+
+// conflictResolvers decide, for --merge --on-conflict, whether a section
+// found in both files with different keys is taken from the overlay.
+var conflictResolvers = map[string]func(name string) (bool, error){
+	"keep-base":    func(string) (bool, error) { return false, nil },
+	"keep-overlay": func(string) (bool, error) { return true, nil },
+	"error": func(name string) (bool, error) {
+		return false, fmt.Errorf("section [%s] differs between the files", name)
+	},
+	"prompt": promptConflict,
+}
+
+// promptConflict asks on the terminal which version of section name to
+// keep.
+func promptConflict(name string) (bool, error) {
+	for {
+		fmt.Fprintf(os.Stderr, "section [%s] differs: keep (b)ase or (o)verlay? [b/o] ", name)
+		answer, err := stdin.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "b", "base":
+			return false, nil
+		case "o", "overlay":
+			return true, nil
+		}
+		if err == io.EOF {
+			return false, fmt.Errorf("no choice made for section [%s]", name)
+		} else if err != nil {
+			return false, err
+		}
+	}
+}
+
+// mergeINI adds the sections of overlay to base, together with the
+// comments above them. A section in both files whose keys differ is
+// replaced by the overlay's when resolve says so; its comments are the
+// base's. Lines before the first section of overlay are left out.
+func mergeINI(base, overlay *iniDocument, resolve func(name string) (bool, error)) error {
+	if resolve == nil {
+		return errors.New("no conflict resolution")
+	}
+	overlayConfig := overlay.config()
+	baseConfig := base.config()
+	for _, section := range overlay.Sections {
+		if section.Header == "" {
+			continue
+		}
+		existing, ok := base.section(section.Name)
+		if !ok {
+			added := *section
+			if len(base.Sections) > 0 {
+				base.Sections[len(base.Sections)-1].endLine()
+				if len(added.Comments) == 0 || strings.TrimSpace(added.Comments[0]) != "" {
+					added.Comments = append([]string{"\n"}, added.Comments...)
+				}
+			}
+			base.Sections = append(base.Sections, &added)
+			continue
+		}
+		if maps.Equal(baseConfig[existing.Name], overlayConfig[section.Name]) {
+			continue
+		}
+		useOverlay, err := resolve(existing.Name)
+		if err != nil {
+			return err
+		}
+		if useOverlay {
+			existing.Lines = append([]string(nil), section.Lines...)
+			existing.endLine()
+		}
+	}
+	if n := len(base.Sections); n > 0 {
+		base.Sections[n-1].endLine()
+	}
+	return nil
+}
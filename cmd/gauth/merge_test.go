@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+const (
+	mergeBase    = "; base\n[github]\nsecret = JBSWY3DPEHPK3PXP\n\n[gitlab]\nsecret = GEZDGNBVGY3TQOJQ\n"
+	mergeOverlay = "[gitlab]\nsecret = MFRGGZDFMZTWQ2LK\n\n; work\n[aws]\nsecret = ONSWG4TFOQ\n"
+)
+
+func TestMergeINI(t *testing.T) {
+	tests := []struct {
+		strategy string
+		gitlab   string
+		err      bool
+	}{
+		{"keep-base", "GEZDGNBVGY3TQOJQ", false},
+		{"keep-overlay", "MFRGGZDFMZTWQ2LK", false},
+		{"error", "", true},
+	}
+	for _, test := range tests {
+		base := parseINIDocument(mergeBase)
+		err := mergeINI(base, parseINIDocument(mergeOverlay), conflictResolvers[test.strategy])
+		if test.err {
+			if err == nil || !strings.Contains(err.Error(), "[gitlab]") {
+				t.Errorf("%s: error %v, want one naming [gitlab]", test.strategy, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.strategy, err)
+		}
+		want := "; base\n[github]\nsecret = JBSWY3DPEHPK3PXP\n\n[gitlab]\nsecret = " + test.gitlab +
+			"\n\n; work\n[aws]\nsecret = ONSWG4TFOQ\n"
+		if got := base.String(); got != want {
+			t.Errorf("%s: got\n%q\nwant\n%q", test.strategy, got, want)
+		}
+	}
+}
+
+func TestMergeINIPrompt(t *testing.T) {
+	saved := stdin
+	t.Cleanup(func() { stdin = saved })
+
+	stdin = bufio.NewReader(strings.NewReader("maybe\no\n"))
+	base := parseINIDocument(mergeBase)
+	if err := mergeINI(base, parseINIDocument(mergeOverlay), conflictResolvers["prompt"]); err != nil {
+		t.Fatal(err)
+	}
+	if got := base.config()["gitlab"]["secret"]; got != "MFRGGZDFMZTWQ2LK" {
+		t.Errorf("answering o kept secret %q", got)
+	}
+
+	stdin = bufio.NewReader(strings.NewReader("b"))
+	base = parseINIDocument(mergeBase)
+	if err := mergeINI(base, parseINIDocument(mergeOverlay), conflictResolvers["prompt"]); err != nil {
+		t.Fatal(err)
+	}
+	if got := base.config()["gitlab"]["secret"]; got != "GEZDGNBVGY3TQOJQ" {
+		t.Errorf("answering b kept secret %q", got)
+	}
+
+	stdin = bufio.NewReader(strings.NewReader(""))
+	if err := mergeINI(parseINIDocument(mergeBase), parseINIDocument(mergeOverlay), conflictResolvers["prompt"]); err == nil {
+		t.Error("no answer merged anyway")
+	}
+}
+
+func TestMergeINISameSection(t *testing.T) {
+	base := parseINIDocument(mergeBase)
+	overlay := parseINIDocument("[github]\n  secret=JBSWY3DPEHPK3PXP\n")
+	if err := mergeINI(base, overlay, conflictResolvers["error"]); err != nil {
+		t.Fatalf("equal sections reported as a conflict: %v", err)
+	}
+	if got := base.String(); got != mergeBase {
+		t.Errorf("got\n%q\nwant\n%q", got, mergeBase)
+	}
+}
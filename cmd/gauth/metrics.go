@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// serverMetrics are the Prometheus metrics of --serve --metrics. They
+// live in their own registry, so /metrics shows nothing else.
+type serverMetrics struct {
+	registry       *prometheus.Registry
+	verifyTotal    *prometheus.CounterVec
+	verifyDuration prometheus.Histogram
+}
+
+func newServerMetrics(accounts func() int) *serverMetrics {
+	m := &serverMetrics{
+		registry: prometheus.NewRegistry(),
+		verifyTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gauth_verify_total",
+			Help: "Number of codes verified, by result.",
+		}, []string{"result"}),
+		verifyDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "gauth_verify_duration_seconds",
+			Help:    "Time taken to verify a code.",
+			Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
+		}),
+	}
+	accountsTotal := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "gauth_accounts_total",
+		Help: "Number of accounts served.",
+	}, func() float64 { return float64(accounts()) })
+	m.registry.MustRegister(m.verifyTotal, m.verifyDuration, accountsTotal)
+	// Both results are exported from the start.
+	m.verifyTotal.WithLabelValues("success")
+	m.verifyTotal.WithLabelValues("failure")
+	return m
+}
+
+// observeVerify records a verification that took d.
+func (m *serverMetrics) observeVerify(ok bool, d time.Duration) {
+	result := "failure"
+	if ok {
+		result = "success"
+	}
+	m.verifyTotal.WithLabelValues(result).Inc()
+	m.verifyDuration.Observe(d.Seconds())
+}
+
+func (m *serverMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
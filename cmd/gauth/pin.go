@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"strings"
+)
+
+// INI keys of an account's static PIN, entered before or after its code.
+const (
+	pinPrefixKey = "pin_prefix"
+	pinSuffixKey = "pin_suffix"
+)
+
+// pin is a static PIN some deployments expect together with the code:
+// Prefix+code, code+Suffix or both.
+type pin struct {
+	Prefix string
+	Suffix string
+}
+
+func addPINFlags(fs *flag.FlagSet, p *pin) {
+	fs.StringVar(&p.Prefix, "pin-prefix", "", "static PIN entered before the code")
+	fs.StringVar(&p.Suffix, "pin-suffix", "", "static PIN entered after the code")
+}
+
+// wrap returns code with the PIN around it.
+func (p pin) wrap(code string) string {
+	return p.Prefix + code + p.Suffix
+}
+
+// strip returns the code inside entered, or false when entered does not
+// carry the PIN.
+func (p pin) strip(entered string) (string, bool) {
+	code, ok := strings.CutPrefix(entered, p.Prefix)
+	if !ok {
+		return "", false
+	}
+	return strings.CutSuffix(code, p.Suffix)
+}
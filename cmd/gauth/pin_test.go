@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// pinCombinations are the four ways a PIN can go with a code.
+var pinCombinations = []pin{{}, {Prefix: "1234"}, {Suffix: "5678"}, {Prefix: "1234", Suffix: "5678"}}
+
+func TestPINWrapStrip(t *testing.T) {
+	wrapped := []string{"94287082", "123494287082", "942870825678", "1234942870825678"}
+	for i, p := range pinCombinations {
+		if got := p.wrap("94287082"); got != wrapped[i] {
+			t.Errorf("%+v: wrap = %q, want %q", p, got, wrapped[i])
+		}
+		if code, ok := p.strip(wrapped[i]); !ok || code != "94287082" {
+			t.Errorf("%+v: strip(%q) = %q, %v", p, wrapped[i], code, ok)
+		}
+		for j, entered := range wrapped {
+			if j == i {
+				continue
+			}
+			// A code with another PIN can only keep the PIN in the
+			// code, which then has the wrong length.
+			if code, ok := p.strip(entered); ok && code == "94287082" {
+				t.Errorf("%+v: strip(%q) accepted the PIN of %+v", p, entered, pinCombinations[j])
+			}
+		}
+	}
+}
+
+func TestVerifyPIN(t *testing.T) {
+	fakeClock(t, 59)
+	for _, p := range pinCombinations {
+		content := "[rfc]\nsecret = GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\ndigits = 8\n"
+		if p.Prefix != "" {
+			content += fmt.Sprintf("%s = %s\n", pinPrefixKey, p.Prefix)
+		}
+		if p.Suffix != "" {
+			content += fmt.Sprintf("%s = %s\n", pinSuffixKey, p.Suffix)
+		}
+		acct := mustFindAccount(t, loadAccounts(t, writeTemp(t, "secrets.ini", content)), "rfc")
+		if acct.PIN != p {
+			t.Errorf("PIN %+v read, want %+v", acct.PIN, p)
+		}
+		wrong := pin{}
+		if p.Prefix != "" {
+			wrong.Prefix = "4321"
+		}
+		if p.Suffix != "" {
+			wrong.Suffix = "8765"
+		}
+		tests := []struct {
+			entered string
+			ok      bool
+		}{
+			{p.wrap("94287082"), true},
+			{p.wrap("12345678"), false},
+			{"94287082", p == pin{}}, // without the PIN
+			{wrong.wrap("94287082"), p == pin{}},
+		}
+		for _, test := range tests {
+			if _, ok, _ := acct.verify(test.entered); ok != test.ok {
+				t.Errorf("%+v: verify(%q) = %v, want %v", p, test.entered, ok, test.ok)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"strings"
+
+	"gauth/qr"
+)
+
+// quietZone is the light border, in modules, around rendered QR codes.
+const quietZone = 2
+
+// pngQuietZone is the border around QR codes written as PNG, which the
+// QR standard requires to be four modules wide.
+const pngQuietZone = 4
+
+// pngMinSize is the smallest width, in pixels, of a QR code PNG.
+const pngMinSize = 256
+
+// renderQR encodes text as a QR code drawn with Unicode half blocks,
+// two module rows per line. Light modules are drawn as blocks so the
+// code scans on terminals with a dark background.
+func renderQR(text string) (string, error) {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for y := -quietZone; y < code.Size+quietZone; y += 2 {
+		for x := -quietZone; x < code.Size+quietZone; x++ {
+			top := !code.Black(x, y)
+			bottom := !code.Black(x, y+1) && y+1 < code.Size+quietZone
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// writeQRPNG encodes text as a QR code and writes it to filename as a
+// PNG image at least pngMinSize pixels wide. The file is created with
+// mode 0600 as the code holds the secret.
+func writeQRPNG(filename, text string) error {
+	code, err := qr.Encode(text, qr.M)
+	if err != nil {
+		return err
+	}
+	modules := code.Size + 2*pngQuietZone
+	scale := (pngMinSize + modules - 1) / modules
+
+	var b bytes.Buffer
+	if err := png.Encode(&b, code.Image(scale, pngQuietZone)); err != nil {
+		return fmt.Errorf("can not encode PNG: %w", err)
+	}
+	return writeFileAtomic(filename, b.Bytes())
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// parseQR turns the output of renderQR back into rows of modules, true
+// for dark, including the quiet zone.
+func parseQR(t *testing.T, rendered string) [][]bool {
+	t.Helper()
+	var rows [][]bool
+	for _, line := range strings.Split(strings.TrimSuffix(rendered, "\n"), "\n") {
+		var top, bottom []bool
+		for _, r := range line {
+			switch r {
+			case '█':
+				top, bottom = append(top, false), append(bottom, false)
+			case '▀':
+				top, bottom = append(top, false), append(bottom, true)
+			case '▄':
+				top, bottom = append(top, true), append(bottom, false)
+			case ' ':
+				top, bottom = append(top, true), append(bottom, true)
+			default:
+				t.Fatalf("unexpected character %q in QR code", r)
+			}
+		}
+		rows = append(rows, top, bottom)
+	}
+	return rows
+}
+
+func TestRenderQRFinderPatterns(t *testing.T) {
+	rendered, err := renderQR("otpauth://totp/alice@example.com?secret=JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := parseQR(t, rendered)
+	size := len(rows[0]) - 2*quietZone
+	// The half blocks of the last line may cover one row past the
+	// quiet zone.
+	if len(rows) < size+2*quietZone {
+		t.Fatalf("%d rows for a code of %d modules", len(rows), size)
+	}
+	dark := func(x, y int) bool { return rows[y+quietZone][x+quietZone] }
+	for _, corner := range [][2]int{{0, 0}, {size - 7, 0}, {0, size - 7}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				ring := max(abs(dx-3), abs(dy-3))
+				want := ring != 2 // dark outer ring and 3×3 center
+				if got := dark(corner[0]+dx, corner[1]+dy); got != want {
+					t.Fatalf("finder pattern at %v: module %d,%d is dark=%v", corner, dx, dy, got)
+				}
+			}
+		}
+	}
+	if dark(-1, 0) || dark(0, -1) {
+		t.Error("quiet zone is not light")
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"container/list"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	verifyRate        = 10    // POST /verify requests per second per client
+	maxLimitedClients = 10000 // clients whose request rate is tracked
+)
+
+// bucket is the token bucket of a client of a rateLimiter.
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter limits the requests of each client IP address with a
+// token bucket refilled at rate tokens a second, holding at most rate
+// tokens. The buckets of the least recently seen clients are dropped
+// beyond size clients.
+type rateLimiter struct {
+	rate float64
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	recent  list.List // of *bucket, most recently seen first
+}
+
+func newRateLimiter(rate float64, size int) *rateLimiter {
+	return &rateLimiter{
+		rate:    rate,
+		size:    size,
+		now:     time.Now,
+		buckets: make(map[string]*list.Element),
+	}
+}
+
+// allow takes a token from the bucket of key. When it is empty, allow
+// returns false and how long until a token is available.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	var b *bucket
+	if e, ok := l.buckets[key]; ok {
+		l.recent.MoveToFront(e)
+		b = e.Value.(*bucket)
+		b.tokens = math.Min(l.rate, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+	} else {
+		b = &bucket{key: key, tokens: l.rate, last: now}
+		l.buckets[key] = l.recent.PushFront(b)
+		if l.recent.Len() > l.size {
+			oldest := l.recent.Back()
+			l.recent.Remove(oldest)
+			delete(l.buckets, oldest.Value.(*bucket).key)
+		}
+	}
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// limit answers 429 Too Many Requests with a Retry-After header to
+// clients exceeding the rate of l.
+func (l *rateLimiter) limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if ok, wait := l.allow(host); !ok {
+			seconds := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+			writeJSON(w, http.StatusTooManyRequests, verifyResponse{Error: "too many requests"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := newRateLimiter(10, 2)
+	l.now = func() time.Time { return now }
+	for i := range 10 {
+		if ok, _ := l.allow("a"); !ok {
+			t.Fatalf("request %d refused", i+1)
+		}
+	}
+	if ok, wait := l.allow("a"); ok || wait != 100*time.Millisecond {
+		t.Errorf("11th request: %v, wait %v", ok, wait)
+	}
+	if ok, _ := l.allow("b"); !ok {
+		t.Error("other client refused")
+	}
+	now = now.Add(100 * time.Millisecond)
+	if ok, _ := l.allow("a"); !ok {
+		t.Error("request refused after a token was refilled")
+	}
+
+	// A third client drops the bucket of b, the least recently seen.
+	l.allow("c")
+	if _, ok := l.buckets["b"]; ok || len(l.buckets) != 2 {
+		t.Errorf("tracked %d clients, b among them: %v", len(l.buckets), ok)
+	}
+}
+
+func TestRateLimiterHandler(t *testing.T) {
+	l := newRateLimiter(1, maxLimitedClients)
+	l.now = func() time.Time { return time.Unix(1000, 0) }
+	handler := l.limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("POST", "/verify", nil))
+		if w.Code != want {
+			t.Errorf("request %d: status %d, want %d", i+1, w.Code, want)
+		}
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/verify", nil))
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After %q, want 1", got)
+	}
+}
+
+func BenchmarkRateLimiterAllow(b *testing.B) {
+	l := newRateLimiter(verifyRate, maxLimitedClients)
+	for b.Loop() {
+		l.allow("192.0.2.1")
+	}
+}
+
+// BenchmarkRateLimiterManyClients measures allow when every request
+// comes from a new client and evicts the least recently seen one.
+func BenchmarkRateLimiterManyClients(b *testing.B) {
+	l := newRateLimiter(verifyRate, maxLimitedClients)
+	keys := make([]string, 4*maxLimitedClients)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	i := 0
+	for b.Loop() {
+		l.allow(keys[i%len(keys)])
+		i++
+	}
+}
+
+func BenchmarkRateLimiterHandler(b *testing.B) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	limited := newRateLimiter(1e9, maxLimitedClients).limit(next)
+	r := httptest.NewRequest("POST", "/verify", nil)
+	b.Run("direct", func(b *testing.B) {
+		for b.Loop() {
+			next.ServeHTTP(httptest.NewRecorder(), r)
+		}
+	})
+	b.Run("limited", func(b *testing.B) {
+		for b.Loop() {
+			limited.ServeHTTP(httptest.NewRecorder(), r)
+		}
+	})
+}
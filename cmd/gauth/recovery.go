@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gauth"
+)
+
+// recoverySection is the INI section holding the recovery codes of the
+// accounts, one key per account:
+//
+//	[recovery-codes]
+//	github = 1A2B-3C4D-5E6F-7A8B, 9C0D-1E2F-3A4B-5C6D
+//
+// loadConfig leaves it out of the accounts.
+const recoverySection = "recovery-codes"
+
+// recoveryCodes returns the recovery codes stored in an INI file by
+// account name.
+func recoveryCodes(filename string) (map[string][]string, error) {
+	doc, err := loadINI(filename)
+	if err != nil {
+		return nil, err
+	}
+	codes := make(map[string][]string)
+	section, ok := doc.section(recoverySection)
+	if !ok {
+		return codes, nil
+	}
+	for _, line := range section.Lines {
+		name, value, ok := keyValue(line)
+		if !ok {
+			continue
+		}
+		codes[name] = strings.FieldsFunc(value, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+	}
+	return codes, nil
+}
+
+// saveCreated adds the section of a new account to the INI file, and
+// its recovery codes, if any, to the recovery section, in one write.
+func saveCreated(filename string, section newSection, codes []string) error {
+	if err := checkWritable(filename); err != nil {
+		return err
+	}
+	doc, err := loadINI(filename)
+	if errors.Is(err, gauth.ErrFileNotFound) {
+		doc, err = &iniDocument{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := doc.section(section.Name); ok {
+		return fmt.Errorf("section [%s] already exists", section.Name)
+	}
+	doc.add(section.Name, section.Values)
+	if len(codes) > 0 {
+		recovery, ok := doc.section(recoverySection)
+		if !ok {
+			doc.add(recoverySection, nil)
+			recovery, _ = doc.section(recoverySection)
+		}
+		recovery.set(map[string]string{section.Name: strings.Join(codes, ", ")})
+	}
+	return saveINI(filename, doc)
+}
+
+// printRecoveryCodes prints the codes of the named account, or of all
+// accounts when name is empty.
+func printRecoveryCodes(codes map[string][]string, name string) error {
+	if name != "" {
+		for key, list := range codes {
+			if strings.EqualFold(key, name) {
+				for _, code := range list {
+					fmt.Println(code)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("no recovery codes for [%s]", name)
+	}
+	names := make([]string, 0, len(codes))
+	for key := range codes {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		fmt.Printf("%s:\n", key)
+		for _, code := range codes[key] {
+			fmt.Println("   ", code)
+		}
+	}
+	return nil
+}
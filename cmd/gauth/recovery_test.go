@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"gauth"
+)
+
+func TestSaveCreatedRecoveryCodes(t *testing.T) {
+	filename := writeTemp(t, "secrets.ini", "[github]\nsecret = JBSWY3DPEHPK3PXP\n")
+	cfg := gauth.DefaultConfig
+	cfg.Digits = 8
+	section := createdSection(cfg, "alice", "example.com", "GEZDGNBVGY3TQOJQ", false)
+	codes := []string{"1A2B-3C4D-5E6F-7A8B", "9C0D-1E2F-3A4B-5C6D"}
+	if err := saveCreated(filename, section, codes); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveCreated(filename, createdSection(cfg, "bob", "", "MZXW6YTBOI", true), []string{"0000-1111-2222-3333"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveCreated(filename, section, nil); err == nil {
+		t.Error("saving an account twice succeeded")
+	}
+
+	stored, err := recoveryCodes(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"alice@example.com": codes, "bob@": {"0000-1111-2222-3333"}}
+	if !reflect.DeepEqual(stored, want) {
+		t.Errorf("recovery codes = %q, want %q", stored, want)
+	}
+
+	config, order, err := loadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"github", "alice@example.com", "bob@"}; !slices.Equal(order, want) {
+		t.Errorf("accounts = %q, want %q", order, want)
+	}
+	wantAlice := map[string]string{"secret": "GEZDGNBVGY3TQOJQ", "user": "alice", "domain": "example.com", "digits": "8"}
+	if !reflect.DeepEqual(config["alice@example.com"], wantAlice) {
+		t.Errorf("[alice@example.com] = %q, want %q", config["alice@example.com"], wantAlice)
+	}
+	if got := config["bob@"]["type"]; got != "hotp" {
+		t.Errorf("[bob@] has type %q, want hotp", got)
+	}
+}
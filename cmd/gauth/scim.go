@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gauth"
+)
+
+// SCIM schema URNs of the provisioning endpoints of --serve --scim.
+// TOTPFactor is not a standard SCIM resource, hence its own URN.
+const (
+	scimFactorSchema = "urn:gauth:params:scim:schemas:core:2.0:TOTPFactor"
+	scimErrorSchema  = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// maxSCIMBody is the largest request body accepted by POST
+// /scim/v2/TOTPFactors.
+const maxSCIMBody = 64 << 10
+
+// scimFactorRequest is the body of POST /scim/v2/TOTPFactors. It must
+// match scimFactorRequestSchema.
+type scimFactorRequest struct {
+	Schemas    []string `json:"schemas"`
+	UserName   string   `json:"userName"`
+	Domain     string   `json:"domain"`
+	ExternalID string   `json:"externalId"`
+}
+
+// scimFactorRequestSchema is the JSON Schema of scimFactorRequest,
+// checked by validateSchema before the body is decoded.
+const scimFactorRequestSchema = `{
+	"type": "object",
+	"required": ["schemas", "userName"],
+	"additionalProperties": false,
+	"properties": {
+		"schemas": {"type": "array", "items": {"type": "string"}},
+		"userName": {"type": "string", "minLength": 1},
+		"domain": {"type": "string"},
+		"externalId": {"type": "string"}
+	}
+}`
+
+// scimFactor is a TOTP factor as returned by the SCIM endpoints. Its id
+// is the section name of the account in the secrets file.
+type scimFactor struct {
+	Schemas    []string `json:"schemas"`
+	ID         string   `json:"id"`
+	ExternalID string   `json:"externalId,omitempty"`
+	UserName   string   `json:"userName"`
+	Domain     string   `json:"domain,omitempty"`
+	Secret     string   `json:"secret"`
+	URL        string   `json:"otpauthUrl"`
+	Meta       scimMeta `json:"meta"`
+}
+
+type scimMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+// scimError is the body of SCIM error responses.
+type scimError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+func writeSCIMError(w http.ResponseWriter, status int, scimType, detail string) {
+	writeJSON(w, status, scimError{
+		Schemas:  []string{scimErrorSchema},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	})
+}
+
+// jsonSchema is the subset of JSON Schema used by scimFactorRequestSchema.
+type jsonSchema struct {
+	Type                 string                 `json:"type"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Items                *jsonSchema            `json:"items"`
+	MinLength            int                    `json:"minLength"`
+}
+
+// validateSchema checks value, as decoded by encoding/json into an any,
+// against schema and describes the first mismatch found at path.
+func validateSchema(schema *jsonSchema, value any, path string) error {
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s.%s is required", path, name)
+			}
+		}
+		for name, v := range object {
+			property, ok := schema.Properties[name]
+			if !ok {
+				if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+					return fmt.Errorf("%s.%s is not allowed", path, name)
+				}
+				continue
+			}
+			if err := validateSchema(property, v, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s must be an array", path)
+		}
+		if schema.Items != nil {
+			for i, v := range array {
+				if err := validateSchema(schema.Items, v, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		if len(s) < schema.MinLength {
+			return fmt.Errorf("%s must not be empty", path)
+		}
+	}
+	return nil
+}
+
+// accountStore holds the accounts served by --serve, which the SCIM
+// endpoints replace after each change to the secrets file.
+type accountStore struct {
+	mu    sync.RWMutex
+	table []account
+}
+
+func (s *accountStore) accounts() []account {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.table
+}
+
+// update applies change to the secrets file and reloads the accounts
+// from it, with the parameters of cfg for those an entry does not set.
+func (s *accountStore) update(filename string, cfg gauth.Config, change func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := change(); err != nil {
+		return err
+	}
+	config, _, err := loadConfig(filename)
+	if err != nil {
+		return err
+	}
+	table, err := newAccounts(cfg, config)
+	if err != nil {
+		return err
+	}
+	s.table = table
+	return nil
+}
+
+// handleSCIM registers POST /scim/v2/TOTPFactors, which generates the
+// secret of a new account and adds it to the secrets file, and DELETE
+// /scim/v2/TOTPFactors/{id}, which removes one, on mux.
+func handleSCIM(mux *http.ServeMux, store *accountStore, opts serveOptions) {
+	var schema jsonSchema
+	if err := json.Unmarshal([]byte(scimFactorRequestSchema), &schema); err != nil {
+		panic(err)
+	}
+	mux.HandleFunc("POST /scim/v2/TOTPFactors", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSCIMBody))
+		if err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+			return
+		}
+		var value any
+		if err := json.Unmarshal(body, &value); err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+			return
+		}
+		if err := validateSchema(&schema, value, "$"); err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+		var req scimFactorRequest
+		if err := json.NewDecoder(bytes.NewReader(body)).Decode(&req); err != nil {
+			writeSCIMError(w, http.StatusBadRequest, "invalidSyntax", err.Error())
+			return
+		}
+		if !slices.Contains(req.Schemas, scimFactorSchema) {
+			writeSCIMError(w, http.StatusBadRequest, "invalidValue", "schemas must contain "+scimFactorSchema)
+			return
+		}
+
+		secret, err := gauth.GenerateSecretKey()
+		if err != nil {
+			writeSCIMError(w, http.StatusInternalServerError, "", "can not generate secret: "+err.Error())
+			return
+		}
+		id := req.UserName + "@" + req.Domain
+		if strings.ContainsAny(id, "[]/\r\n") {
+			writeSCIMError(w, http.StatusBadRequest, "invalidValue", "userName and domain must not contain [, ], / or line breaks")
+			return
+		}
+		values := map[string]string{"secret": secret, "user": req.UserName, "domain": req.Domain}
+		err = store.update(opts.Filename, opts.Config, func() error {
+			if hasSection(store.table, id) {
+				return errSCIMConflict
+			}
+			return addSection(opts.Filename, id, values)
+		})
+		switch {
+		case errors.Is(err, errSCIMConflict):
+			writeSCIMError(w, http.StatusConflict, "uniqueness", fmt.Sprintf("factor %q already exists", id))
+			return
+		case err != nil:
+			writeSCIMError(w, http.StatusInternalServerError, "", err.Error())
+			return
+		}
+		location := "/scim/v2/TOTPFactors/" + id
+		w.Header().Set("Location", location)
+		writeJSON(w, http.StatusCreated, scimFactor{
+			Schemas:    []string{scimFactorSchema},
+			ID:         id,
+			ExternalID: req.ExternalID,
+			UserName:   req.UserName,
+			Domain:     req.Domain,
+			Secret:     secret,
+			URL:        opts.Config.OTPAuthURL(req.UserName, req.Domain, secret),
+			Meta:       scimMeta{ResourceType: "TOTPFactor", Location: location},
+		})
+	})
+	mux.HandleFunc("DELETE /scim/v2/TOTPFactors/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		err := store.update(opts.Filename, opts.Config, func() error {
+			if !hasSection(store.table, id) {
+				return errSCIMNotFound
+			}
+			_, err := removeSection(opts.Filename, id)
+			return err
+		})
+		switch {
+		case errors.Is(err, errSCIMNotFound):
+			writeSCIMError(w, http.StatusNotFound, "", fmt.Sprintf("factor %q not found", id))
+		case err != nil:
+			writeSCIMError(w, http.StatusInternalServerError, "", err.Error())
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+var (
+	errSCIMConflict = errors.New("factor already exists")
+	errSCIMNotFound = errors.New("factor not found")
+)
+
+// hasSection reports whether an account of table has the section name,
+// ignoring case like the INI file functions do.
+func hasSection(table []account, name string) bool {
+	return slices.ContainsFunc(table, func(acct account) bool {
+		return strings.EqualFold(acct.Name, name)
+	})
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gauth"
+)
+
+const scimSecrets = "[github]\nsecret = JBSWY3DPEHPK3PXP\n"
+
+// postFactor posts body to /scim/v2/TOTPFactors and decodes the response
+// into v, returning the status code.
+func postFactor(t *testing.T, srv *httptest.Server, body string, v any) int {
+	t.Helper()
+	return request(t, srv, "POST", "/scim/v2/TOTPFactors", body, v)
+}
+
+func TestSCIMCreate(t *testing.T) {
+	srv, filename := newTestServer(t, scimSecrets, serveOptions{SCIM: true})
+	body := `{"schemas": ["` + scimFactorSchema + `"], "userName": "alice", "domain": "example.com", "externalId": "42"}`
+	var factor scimFactor
+	if status := postFactor(t, srv, body, &factor); status != http.StatusCreated {
+		t.Fatalf("POST: %d %+v, want 201", status, factor)
+	}
+	if factor.ID != "alice@example.com" || factor.ExternalID != "42" ||
+		factor.Meta.Location != "/scim/v2/TOTPFactors/alice@example.com" {
+		t.Errorf("created %+v", factor)
+	}
+	if err := gauth.ValidateSecret(factor.Secret); err != nil {
+		t.Errorf("created secret %q: %v", factor.Secret, err)
+	}
+	acct := mustFindAccount(t, loadAccounts(t, filename), "alice@example.com")
+	if acct.Secret != factor.Secret {
+		t.Errorf("file holds secret %q, want %q", acct.Secret, factor.Secret)
+	}
+	var code codeResponse
+	if status := request(t, srv, "GET", "/code?account=alice@example.com", "", &code); status != http.StatusOK || code.Code == "" {
+		t.Errorf("GET /code of the new factor: %d %+v", status, code)
+	}
+
+	for _, userName := range []string{"alice", "ALICE"} {
+		body := `{"schemas": ["` + scimFactorSchema + `"], "userName": "` + userName + `", "domain": "example.com"}`
+		var scimErr scimError
+		if status := postFactor(t, srv, body, &scimErr); status != http.StatusConflict || scimErr.SCIMType != "uniqueness" {
+			t.Errorf("POST of %s again: %d %+v, want 409 uniqueness", userName, status, scimErr)
+		}
+	}
+}
+
+func TestSCIMSchema(t *testing.T) {
+	srv, filename := newTestServer(t, scimSecrets, serveOptions{SCIM: true})
+	tests := []struct {
+		body     string
+		scimType string
+	}{
+		{`{"userName": "alice"}`, "invalidValue"},
+		{`{"schemas": [], "userName": "alice"}`, "invalidValue"},
+		{`{"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"], "userName": "alice"}`, "invalidValue"},
+		{`{"schemas": "` + scimFactorSchema + `", "userName": "alice"}`, "invalidValue"},
+		{`{"schemas": [1], "userName": "alice"}`, "invalidValue"},
+		{`{"schemas": ["` + scimFactorSchema + `"]}`, "invalidValue"},
+		{`{"schemas": ["` + scimFactorSchema + `"], "userName": ""}`, "invalidValue"},
+		{`{"schemas": ["` + scimFactorSchema + `"], "userName": 7}`, "invalidValue"},
+		{`{"schemas": ["` + scimFactorSchema + `"], "userName": "alice", "secret": "AAAA"}`, "invalidValue"},
+		{`{"schemas": ["` + scimFactorSchema + `"], "userName": "alice]\nsecret = AAAA"}`, "invalidValue"},
+		{`["` + scimFactorSchema + `"]`, "invalidValue"},
+		{`{"schemas": [`, "invalidSyntax"},
+	}
+	for _, test := range tests {
+		var scimErr scimError
+		status := postFactor(t, srv, test.body, &scimErr)
+		if status != http.StatusBadRequest || scimErr.SCIMType != test.scimType {
+			t.Errorf("POST %s: %d %+v, want 400 %s", test.body, status, scimErr, test.scimType)
+		}
+	}
+	if got := readTemp(t, filename); got != scimSecrets {
+		t.Errorf("rejected requests changed the file to\n%q", got)
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	var schema jsonSchema
+	if err := json.Unmarshal([]byte(scimFactorRequestSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		body string
+		err  string
+	}{
+		{`{"schemas": ["x"], "userName": "alice", "domain": "example.com", "externalId": "1"}`, ""},
+		{`null`, "$ must be an object"},
+		{`{"userName": "alice"}`, "$.schemas is required"},
+		{`{"schemas": ["x", 2], "userName": "alice"}`, "$.schemas[1] must be a string"},
+		{`{"schemas": {}, "userName": "alice"}`, "$.schemas must be an array"},
+		{`{"schemas": [], "userName": ""}`, "$.userName must not be empty"},
+		{`{"schemas": [], "userName": "alice", "id": "x"}`, "$.id is not allowed"},
+	}
+	for _, test := range tests {
+		var value any
+		if err := json.Unmarshal([]byte(test.body), &value); err != nil {
+			t.Fatal(err)
+		}
+		err := validateSchema(&schema, value, "$")
+		if test.err == "" && err != nil || test.err != "" && (err == nil || err.Error() != test.err) {
+			t.Errorf("%s: error %v, want %q", test.body, err, test.err)
+		}
+	}
+}
+
+func TestSCIMToken(t *testing.T) {
+	srv, filename := newTestServer(t, scimSecrets, serveOptions{SCIM: true})
+	body := `{"schemas": ["` + scimFactorSchema + `"], "userName": "alice"}`
+	for _, header := range []string{"", "Bearer wrong"} {
+		for _, method := range []string{"POST", "DELETE"} {
+			path := "/scim/v2/TOTPFactors"
+			if method == "DELETE" {
+				path += "/github"
+			}
+			req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("%s %s with Authorization %q: %d, want 401", method, path, header, resp.StatusCode)
+			}
+		}
+	}
+	if got := readTemp(t, filename); got != scimSecrets {
+		t.Errorf("unauthorized requests changed the file to\n%q", got)
+	}
+}
+
+func TestSCIMDelete(t *testing.T) {
+	srv, filename := newTestServer(t, scimSecrets+"\n[gitlab]\nsecret = GEZDGNBVGY3TQOJQ\n", serveOptions{SCIM: true})
+	if status := request(t, srv, "DELETE", "/scim/v2/TOTPFactors/GitHub", "", nil); status != http.StatusNoContent {
+		t.Fatalf("DELETE of a present factor: %d, want 204", status)
+	}
+	if got, want := readTemp(t, filename), "\n[gitlab]\nsecret = GEZDGNBVGY3TQOJQ\n"; got != want {
+		t.Errorf("after DELETE the file is\n%q\nwant\n%q", got, want)
+	}
+	var code codeResponse
+	if status := request(t, srv, "GET", "/code?account=github", "", &code); status == http.StatusOK {
+		t.Errorf("GET /code of the deleted factor: %d %+v", status, code)
+	}
+
+	for _, id := range []string{"github", "missing"} {
+		var scimErr scimError
+		if status := request(t, srv, "DELETE", "/scim/v2/TOTPFactors/"+id, "", &scimErr); status != http.StatusNotFound {
+			t.Errorf("DELETE of absent factor %s: %d %+v, want 404", id, status, scimErr)
+		}
+	}
+}
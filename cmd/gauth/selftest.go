@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/base32"
+	"fmt"
+	"time"
+
+	"gauth"
+)
+
+// rfc6238Seeds are the ASCII seeds of RFC 6238 appendix B, one per
+// algorithm, sized to the output of the hash function.
+var rfc6238Seeds = map[gauth.Algorithm]string{
+	gauth.SHA1:   "12345678901234567890",
+	gauth.SHA256: "12345678901234567890123456789012",
+	gauth.SHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+}
+
+// rfc6238Vectors are the test vectors of RFC 6238 appendix B.
+var rfc6238Vectors = []struct {
+	Time      int64
+	Algorithm gauth.Algorithm
+	Code      string
+}{
+	{59, gauth.SHA1, "94287082"},
+	{59, gauth.SHA256, "46119246"},
+	{59, gauth.SHA512, "90693936"},
+	{1111111109, gauth.SHA1, "07081804"},
+	{1111111109, gauth.SHA256, "68084774"},
+	{1111111109, gauth.SHA512, "25091201"},
+	{1111111111, gauth.SHA1, "14050471"},
+	{1111111111, gauth.SHA256, "67062674"},
+	{1111111111, gauth.SHA512, "99943326"},
+	{1234567890, gauth.SHA1, "89005924"},
+	{1234567890, gauth.SHA256, "91819424"},
+	{1234567890, gauth.SHA512, "93441116"},
+	{2000000000, gauth.SHA1, "69279037"},
+	{2000000000, gauth.SHA256, "90698825"},
+	{2000000000, gauth.SHA512, "38618901"},
+	{20000000000, gauth.SHA1, "65353130"},
+	{20000000000, gauth.SHA256, "77737706"},
+	{20000000000, gauth.SHA512, "47863826"},
+}
+
+// selfTest checks the RFC 6238 test vectors, printing one line per
+// vector and a summary, and reports whether all of them passed.
+func selfTest() bool {
+	failed := 0
+	for _, v := range rfc6238Vectors {
+		cfg := gauth.Config{Algorithm: v.Algorithm, Digits: 8, Period: 30}
+		secret := base32.StdEncoding.EncodeToString([]byte(rfc6238Seeds[v.Algorithm]))
+		code, err := cfg.GenerateTimeBasedAt(secret, time.Unix(v.Time, 0))
+		result := "PASS"
+		if err != nil || code != v.Code {
+			result = "FAIL"
+			failed++
+		}
+		fmt.Printf("%s  %-6s  %11d  %s", result, v.Algorithm, v.Time, v.Code)
+		if err != nil {
+			fmt.Printf("  (%v)", err)
+		} else if code != v.Code {
+			fmt.Printf("  (got %s)", code)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%d of %d RFC 6238 test vectors passed\n", len(rfc6238Vectors)-failed, len(rfc6238Vectors))
+	return failed == 0
+}
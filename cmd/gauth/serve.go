@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gauth"
+)
+
+// verifyRequest is the body of POST /verify.
+type verifyRequest struct {
+	Account string `json:"account"`
+	Code    string `json:"code"`
+}
+
+// verifyResponse answers POST /verify.
+type verifyResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// healthResponse answers GET /health and GET /ready.
+type healthResponse struct {
+	Status        string `json:"status"`
+	Accounts      int    `json:"accounts"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	Error         string `json:"error,omitempty"`
+}
+
+// serveOptions controls the handler of --serve.
+type serveOptions struct {
+	Filename string        // the secrets file, checked by GET /ready
+	Token    string        // the bearer token clients must present
+	Timeout  time.Duration // the longest time POST /verify may take
+	Metrics  bool          // serve Prometheus metrics at GET /metrics
+	SCIM     bool          // provision accounts at /scim/v2/TOTPFactors
+	Config   gauth.Config  // the parameters of accounts added by SCIM
+
+	// Replay records the time steps of the TOTP codes accepted by POST
+	// /verify, so that none is accepted twice.
+	Replay gauth.ReplayStore
+}
+
+// maxVerifyBody is the largest request body accepted by POST /verify.
+const maxVerifyBody = 4 << 10
+
+// Timeouts of the --serve HTTP server, so that slow or idle clients can
+// not hold connections open.
+const (
+	serveReadHeaderTimeout = 5 * time.Second
+	serveReadTimeout       = 10 * time.Second
+	serveWriteTimeout      = 10 * time.Second
+	serveIdleTimeout       = 60 * time.Second
+)
+
+// newHTTPServer returns the server of --serve listening on addr. The
+// write timeout leaves room for a verification taking up to timeout.
+func newHTTPServer(addr string, handler http.Handler, timeout time.Duration) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: serveReadHeaderTimeout,
+		ReadTimeout:       serveReadTimeout,
+		WriteTimeout:      serveWriteTimeout + timeout,
+		IdleTimeout:       serveIdleTimeout,
+	}
+}
+
+// newHTTPHandler serves GET /code and POST /verify for the accounts in
+// table to clients presenting opts.Token as a bearer token. Each client
+// IP address may verify verifyRate codes a second, and a verification
+// taking longer than opts.Timeout is answered with 503 Service
+// Unavailable. GET /health and GET /ready, for liveness and readiness
+// probes, need no token. With opts.SCIM, handleSCIM adds and removes
+// accounts.
+func newHTTPHandler(table []account, opts serveOptions) http.Handler {
+	started := time.Now()
+	store := &accountStore{table: table}
+	health := func() healthResponse {
+		return healthResponse{Status: "ok", Accounts: len(store.accounts()), UptimeSeconds: int64(time.Since(started).Seconds())}
+	}
+	probes := http.NewServeMux()
+	probes.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, health())
+	})
+	probes.HandleFunc("GET /ready", func(w http.ResponseWriter, r *http.Request) {
+		resp := health()
+		f, err := os.Open(opts.Filename)
+		if err != nil {
+			resp.Status, resp.Error = "unavailable", "secrets file is not readable"
+			writeJSON(w, http.StatusServiceUnavailable, resp)
+			return
+		}
+		f.Close()
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	mux := http.NewServeMux()
+	var metrics *serverMetrics
+	if opts.Metrics {
+		metrics = newServerMetrics(func() int { return len(store.accounts()) })
+		mux.Handle("GET /metrics", metrics.handler())
+	}
+	if opts.SCIM {
+		handleSCIM(mux, store, opts)
+	}
+	mux.HandleFunc("GET /code", func(w http.ResponseWriter, r *http.Request) {
+		resp := accountCode(store.accounts(), r.URL.Query().Get("account"))
+		status := http.StatusOK
+		if resp.Error != "" {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, resp)
+	})
+	limiter := newRateLimiter(verifyRate, maxLimitedClients)
+	mux.Handle("POST /verify", limiter.limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req verifyRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxVerifyBody)).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, verifyResponse{Error: "invalid request: " + err.Error()})
+			return
+		}
+		acct, ok := findAccount(store.accounts(), req.Account)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, verifyResponse{Error: fmt.Sprintf("account %q not found", req.Account)})
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), opts.Timeout)
+		defer cancel()
+		type result struct {
+			ok  bool
+			err error
+		}
+		done := make(chan result, 1)
+		go func() {
+			start := time.Now()
+			ok, err := verifyAccount(store, opts, acct, req.Code)
+			if metrics != nil {
+				metrics.observeVerify(ok, time.Since(start))
+			}
+			done <- result{ok, err}
+		}()
+		var res result
+		select {
+		case res = <-done:
+		case <-ctx.Done():
+			writeJSON(w, http.StatusServiceUnavailable, verifyResponse{Error: "verification timed out"})
+			return
+		}
+		ok, err := res.ok, res.err
+		switch {
+		case errors.Is(err, gauth.ErrInvalidCode):
+			writeJSON(w, http.StatusBadRequest, verifyResponse{Error: err.Error()})
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, verifyResponse{Error: err.Error()})
+		default:
+			writeJSON(w, http.StatusOK, verifyResponse{OK: ok})
+		}
+	})))
+	probes.Handle("/", requireToken(opts.Token, mux))
+	return probes
+}
+
+// verifyAccount checks code against acct so that no code is accepted
+// twice: the time step of a TOTP code is recorded in opts.Replay, and
+// the counter of an HOTP account is moved past the code in the secrets
+// file, holding the lock of store from verifying the code to reloading
+// the accounts.
+func verifyAccount(store *accountStore, opts serveOptions, acct account, code string) (bool, error) {
+	if acct.Type != "hotp" {
+		step, ok, err := acct.verify(code)
+		if !ok || err != nil || opts.Replay == nil {
+			return ok, err
+		}
+		err = opts.Replay.Accept(gauth.ReplayKey(acct.Secret), step)
+		if errors.Is(err, gauth.ErrCodeReused) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	var ok bool
+	err := store.update(opts.Filename, opts.Config, func() error {
+		// Another request may have moved the counter meanwhile.
+		current, found := findAccount(store.table, acct.Name)
+		if !found {
+			return nil
+		}
+		counter, matched, err := current.verify(code)
+		if !matched || err != nil {
+			return err
+		}
+		ok = true
+		return updateSection(opts.Filename, current.Name, map[string]string{
+			"counter":         strconv.FormatUint(counter+1, 10),
+			counterUpdatedKey: time.Now().UTC().Format(time.RFC3339),
+		})
+	})
+	return ok && err == nil, err
+}
+
+// requireToken rejects requests without the "Authorization: Bearer
+// token" header.
+func requireToken(token string, next http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, codeResponse{Error: "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// isLoopback reports whether addr only listens on the local machine.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
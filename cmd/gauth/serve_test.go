@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gauth"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+const testToken = "s3cret"
+
+// newTestServer serves the accounts of an INI file with the content
+// given, returning the server and the file name.
+func newTestServer(t *testing.T, content string, opts serveOptions) (*httptest.Server, string) {
+	t.Helper()
+	filename := writeTemp(t, "secrets.ini", content)
+	opts.Filename, opts.Token, opts.Config = filename, testToken, gauth.DefaultConfig
+	if opts.Timeout == 0 {
+		opts.Timeout = time.Second
+	}
+	srv := httptest.NewServer(newHTTPHandler(loadAccounts(t, filename), opts))
+	t.Cleanup(srv.Close)
+	return srv, filename
+}
+
+// request sends an authorized request and decodes the JSON response
+// into v, returning the status code.
+func request(t *testing.T, srv *httptest.Server, method, path, body string, v any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// postVerify posts code for account to /verify and returns the status
+// code and response.
+func postVerify(t *testing.T, srv *httptest.Server, account, code string) (int, verifyResponse) {
+	t.Helper()
+	var resp verifyResponse
+	status := request(t, srv, "POST", "/verify", fmt.Sprintf(`{"account": %q, "code": %q}`, account, code), &resp)
+	return status, resp
+}
+
+func TestVerifyTOTPReplay(t *testing.T) {
+	fakeClock(t, 1234567890)
+	srv, _ := newTestServer(t, "[github]\nsecret = GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\n",
+		serveOptions{Replay: &gauth.MemoryReplayStore{}})
+	cfg := gauth.DefaultConfig
+	current, _ := cfg.GenerateTimeBasedAt("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", clock())
+	previous, _ := cfg.GenerateTimeBasedAt("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", clock().Add(-30*time.Second))
+
+	tests := []struct {
+		code string
+		ok   bool
+	}{
+		{current, true},
+		{current, false},  // replayed
+		{previous, false}, // of an earlier step than one accepted
+	}
+	for i, test := range tests {
+		status, resp := postVerify(t, srv, "github", test.code)
+		if status != http.StatusOK || resp.OK != test.ok {
+			t.Errorf("request %d: %d %+v, want ok %v", i, status, resp, test.ok)
+		}
+	}
+}
+
+func TestVerifyHOTPCounter(t *testing.T) {
+	srv, filename := newTestServer(t,
+		"[token]\nsecret = GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ\ntype = hotp\ncounter = 5\n", serveOptions{})
+	// The codes of the counters 5 and 6 of RFC 4226 appendix D.
+	tests := []struct {
+		code    string
+		ok      bool
+		counter uint64
+	}{
+		{"287922", true, 7}, // one ahead of the counter
+		{"287922", false, 7},
+		{"254676", false, 7}, // skipped over
+	}
+	for i, test := range tests {
+		status, resp := postVerify(t, srv, "token", test.code)
+		if status != http.StatusOK || resp.OK != test.ok {
+			t.Errorf("request %d: %d %+v, want ok %v", i, status, resp, test.ok)
+		}
+		if got := mustFindAccount(t, loadAccounts(t, filename), "token").Counter; got != test.counter {
+			t.Errorf("request %d: counter %d in the file, want %d", i, got, test.counter)
+		}
+	}
+	var code codeResponse
+	request(t, srv, "GET", "/code?account=token", "", &code)
+	if code.Code != "162583" {
+		t.Errorf("GET /code after verifying = %+v, want the code of counter 7", code)
+	}
+}
+
+func TestVerifyBodyLimit(t *testing.T) {
+	srv, _ := newTestServer(t, "[github]\nsecret = JBSWY3DPEHPK3PXP\n", serveOptions{})
+	body := `{"account": "github", "code": "` + strings.Repeat("1", maxVerifyBody) + `"}`
+	var resp verifyResponse
+	if status := request(t, srv, "POST", "/verify", body, &resp); status != http.StatusBadRequest {
+		t.Errorf("oversized body: %d %+v, want 400", status, resp)
+	}
+}
+
+// slowReplayStore holds every Accept until release is closed.
+type slowReplayStore struct {
+	release chan struct{}
+}
+
+func (s slowReplayStore) Accept(key string, step uint64) error {
+	<-s.release
+	return nil
+}
+
+func TestHTTPServerTimeouts(t *testing.T) {
+	server := newHTTPServer("127.0.0.1:0", http.NotFoundHandler(), time.Minute)
+	if server.ReadHeaderTimeout <= 0 || server.ReadTimeout <= 0 || server.IdleTimeout <= 0 {
+		t.Errorf("server without read or idle timeouts: %+v", server)
+	}
+	if server.WriteTimeout <= time.Minute {
+		t.Errorf("write timeout %v cuts off a verification taking the whole request timeout", server.WriteTimeout)
+	}
+}
+
+func TestVerifyTimeout(t *testing.T) {
+	store := slowReplayStore{release: make(chan struct{})}
+	srv, _ := newTestServer(t, "[github]\nsecret = JBSWY3DPEHPK3PXP\n",
+		serveOptions{Timeout: 50 * time.Millisecond, Replay: store})
+	t.Cleanup(func() { close(store.release) })
+	code, err := gauth.DefaultConfig.GenerateTimeBasedAt("JBSWY3DPEHPK3PXP", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	status, resp := postVerify(t, srv, "github", code)
+	if status != http.StatusServiceUnavailable || resp.Error != "verification timed out" {
+		t.Errorf("slow verification: %d %+v, want 503", status, resp)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("answered after %v", elapsed)
+	}
+}
+
+// probe gets path without a token and decodes the health response.
+func probe(t *testing.T, srv *httptest.Server, path string) (int, healthResponse) {
+	t.Helper()
+	resp, err := http.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var health healthResponse
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, health
+}
+
+func TestHealthAndReady(t *testing.T) {
+	srv, filename := newTestServer(t,
+		"[github]\nsecret = JBSWY3DPEHPK3PXP\n[gitlab]\nsecret = GEZDGNBVGY3TQOJQ\n", serveOptions{})
+	for _, path := range []string{"/health", "/ready"} {
+		status, resp := probe(t, srv, path)
+		if status != http.StatusOK || resp.Status != "ok" || resp.Accounts != 2 || resp.UptimeSeconds != 0 {
+			t.Errorf("GET %s: %d %+v", path, status, resp)
+		}
+	}
+
+	// Only readiness depends on the secrets file.
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+	if status, resp := probe(t, srv, "/health"); status != http.StatusOK || resp.Status != "ok" {
+		t.Errorf("GET /health without the file: %d %+v", status, resp)
+	}
+	status, resp := probe(t, srv, "/ready")
+	if status != http.StatusServiceUnavailable || resp.Status != "unavailable" || resp.Error == "" || resp.Accounts != 2 {
+		t.Errorf("GET /ready without the file: %d %+v", status, resp)
+	}
+
+	if status := request(t, srv, "GET", "/code?account=github", "", nil); status != http.StatusOK {
+		t.Errorf("GET /code with the token: %d", status)
+	}
+	unauthorized, err := http.Get(srv.URL + "/code?account=github")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unauthorized.Body.Close()
+	if unauthorized.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /code without the token: %d", unauthorized.StatusCode)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	srv, _ := newTestServer(t, "[github]\nsecret = JBSWY3DPEHPK3PXP\n[gitlab]\nsecret = GEZDGNBVGY3TQOJQ\n",
+		serveOptions{Metrics: true})
+	code, err := gauth.DefaultConfig.GenerateTimeBasedAt("JBSWY3DPEHPK3PXP", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale, err := gauth.DefaultConfig.GenerateTimeBasedAt("JBSWY3DPEHPK3PXP", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	postVerify(t, srv, "github", code)
+	postVerify(t, srv, "github", stale)
+
+	req, err := http.NewRequest("GET", srv.URL+"/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	parser := expfmt.NewTextParser(model.UTF8Validation)
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		t.Fatalf("parsing /metrics: %v", err)
+	}
+
+	results := make(map[string]float64)
+	for _, m := range families["gauth_verify_total"].GetMetric() {
+		results[m.GetLabel()[0].GetValue()] = m.GetCounter().GetValue()
+	}
+	if results["success"] != 1 || results["failure"] != 1 {
+		t.Errorf("gauth_verify_total = %v, want one success and one failure", results)
+	}
+	if got := families["gauth_verify_duration_seconds"].GetMetric()[0].GetHistogram().GetSampleCount(); got != 2 {
+		t.Errorf("gauth_verify_duration_seconds counted %d verifications, want 2", got)
+	}
+	if got := families["gauth_accounts_total"].GetMetric()[0].GetGauge().GetValue(); got != 2 {
+		t.Errorf("gauth_accounts_total = %v, want 2", got)
+	}
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	srv, _ := newTestServer(t, "[github]\nsecret = JBSWY3DPEHPK3PXP\n", serveOptions{})
+	req, err := http.NewRequest("GET", srv.URL+"/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /metrics without --metrics: %d, want 404", resp.StatusCode)
+	}
+}
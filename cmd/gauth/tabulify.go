@@ -0,0 +1,236 @@
+package main
+
+import "strings"
+
+// alignment is the horizontal alignment of a tabulify column.
+type alignment int
+
+const (
+	alignLeft alignment = iota
+	alignRight
+)
+
+// tabulify renders rows as a table in the given style. align sets the
+// alignment of each column; columns it does not cover are left-aligned.
+// When maxWidth is positive, cells are truncated with "…" so the table
+// fits in that many terminal cells.
+func tabulify(rows [][]string, style string, align []alignment, maxWidth int) string {
+	rightAligned := func(x int) bool {
+		return x < len(align) && align[x] == alignRight
+	}
+	// leading returns the spaces before a cell of column x that needs
+	// padding spaces in total.
+	leading := func(x, padding int) int {
+		if rightAligned(x) {
+			return padding - 1
+		}
+		return 1
+	}
+	colsize := make(map[int]int)
+	maxcol := 0
+	output := []string{}
+	if len(rows) == 0 {
+		return ""
+	}
+	for _, row := range rows {
+		maxcol = max(maxcol, len(row))
+		for col, text := range row {
+			text := text
+			size := displayWidth(text)
+			if _, ok := colsize[col]; !ok {
+				colsize[col] = size
+			} else {
+				colsize[col] = max(size, colsize[col])
+			}
+		}
+	}
+	if maxcol <= 0 {
+		return ""
+	}
+	if maxWidth > 0 {
+		overhead := 2 * maxcol // a space on either side of each cell
+		if style == "2" || style == "3" {
+			overhead += maxcol + 1 // borders
+		}
+		limits := fitColumns(colsize, maxcol, maxWidth-overhead)
+		rows = truncateRows(rows, limits)
+		for x, limit := range limits {
+			colsize[x] = min(colsize[x], limit)
+		}
+	}
+
+	for y, _ := range rows {
+		line := ""
+		for x := 0; x < maxcol; x++ {
+			csize := colsize[x]
+			if y >= len(rows) {
+				line += strings.Repeat(" ", csize+2)
+			} else {
+				row := rows[y]
+				if x >= len(row) {
+					line += strings.Repeat(" ", csize+2)
+				} else {
+					text := row[x]
+					padding := 2 + csize - displayWidth(text)
+					pad1 := leading(x, padding)
+					pad2 := padding - pad1
+					line += strings.Repeat(" ", pad1) + text + strings.Repeat(" ", pad2)
+				}
+			}
+		}
+		output = append(output, line)
+	}
+
+	if style == "0" {
+		return strings.Join(output, "\n")
+	} else if style == "1" {
+		newrows := make([][]string, 0)
+		if len(rows) > 0 {
+			newrows = append(newrows, rows[:1]...)
+			head := []string{}
+			for i := 0; i < maxcol; i++ {
+				head = append(head, strings.Repeat("-", colsize[i]))
+			}
+			newrows = append(newrows, head)
+			newrows = append(newrows, rows[1:]...)
+		}
+		output = []string{}
+		for y, _ := range newrows {
+			line := ""
+			for x := 0; x < maxcol; x++ {
+				csize := colsize[x]
+				if y >= len(newrows) {
+					line += strings.Repeat(" ", csize+2)
+				} else {
+					row := newrows[y]
+					if x >= len(row) {
+						line += strings.Repeat(" ", csize+2)
+					} else {
+						text := row[x]
+						padding := 2 + csize - displayWidth(text)
+						pad1 := leading(x, padding)
+						pad2 := padding - pad1
+						line += strings.Repeat(" ", pad1) + text + strings.Repeat(" ", pad2)
+					}
+				}
+			}
+			output = append(output, line)
+		}
+		return strings.Join(output, "\n")
+	} else if style == "2" {
+		sep := "+"
+		for x := 0; x < maxcol; x++ {
+			sep += strings.Repeat("-", colsize[x]+2) + "+"
+		}
+		output = []string{sep}
+		for y, _ := range rows {
+			line := "|"
+			for x := 0; x < maxcol; x++ {
+				csize := colsize[x]
+				if y >= len(rows) {
+					line += strings.Repeat(" ", csize+2) + "|"
+				} else {
+					row := rows[y]
+					if x >= len(row) {
+						line += strings.Repeat(" ", csize+2) + "|"
+					} else {
+						text := row[x]
+						padding := 2 + csize - displayWidth(text)
+						pad1 := leading(x, padding)
+						pad2 := padding - pad1
+						line += strings.Repeat(" ", pad1) + text + strings.Repeat(" ", pad2) + "|"
+					}
+				}
+			}
+			output = append(output, line)
+			output = append(output, sep)
+		}
+		return strings.Join(output, "\n")
+	} else if style == "3" {
+		output = []string{}
+		for y, row := range rows {
+			line := "|"
+			for x := 0; x < maxcol; x++ {
+				csize := colsize[x]
+				text := ""
+				if x < len(row) {
+					text = strings.ReplaceAll(row[x], "|", "\\|")
+				}
+				padding := max(csize-displayWidth(text), 0) + 2
+				pad1 := leading(x, padding)
+				pad2 := padding - pad1
+				line += strings.Repeat(" ", pad1) + text + strings.Repeat(" ", pad2) + "|"
+			}
+			output = append(output, line)
+			if y == 0 {
+				sep := "|"
+				for x := 0; x < maxcol; x++ {
+					if rightAligned(x) {
+						sep += strings.Repeat("-", colsize[x]+1) + ":|"
+					} else {
+						sep += strings.Repeat("-", colsize[x]+2) + "|"
+					}
+				}
+				output = append(output, sep)
+			}
+		}
+		return strings.Join(output, "\n")
+	}
+	return ""
+}
+
+// fitColumns shares budget cells between maxcol columns of the widths
+// in colsize. Columns narrower than their share keep their width and
+// leave the rest to the wider ones, which are limited to what remains.
+func fitColumns(colsize map[int]int, maxcol, budget int) []int {
+	limits := make([]int, maxcol)
+	var wide []int
+	for x := range limits {
+		limits[x] = colsize[x]
+		wide = append(wide, x)
+	}
+	for len(wide) > 0 {
+		share := budget / len(wide)
+		var rest []int
+		for _, x := range wide {
+			if limits[x] <= share {
+				budget -= limits[x]
+			} else {
+				rest = append(rest, x)
+			}
+		}
+		if len(rest) == len(wide) {
+			for _, x := range rest {
+				limits[x] = max(share, 1)
+			}
+			break
+		}
+		wide = rest
+	}
+	return limits
+}
+
+// truncateRows returns a copy of rows with the cells wider than the
+// limit of their column cut short and ended with "…".
+func truncateRows(rows [][]string, limits []int) [][]string {
+	out := make([][]string, len(rows))
+	for y, row := range rows {
+		out[y] = make([]string, len(row))
+		for x, text := range row {
+			if displayWidth(text) > limits[x] {
+				// Color sequences can not be cut safely and are dropped.
+				runes := []rune(ansiEscape.ReplaceAllString(text, ""))
+				text = string(runes[:limits[x]-1]) + "…"
+			}
+			out[y][x] = text
+		}
+	}
+	return out
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTabulifyMarkdown(t *testing.T) {
+	rows := [][]string{
+		{"Name", "Code", "Life"},
+		{"github", "492039", "5s"},
+		{"a|b", "123456", "25s"},
+	}
+	want := "" +
+		"| Name   | Code   | Life |\n" +
+		"|--------|--------|-----:|\n" +
+		"| github | 492039 |   5s |\n" +
+		"| a\\|b   | 123456 |  25s |"
+	if got := tabulify(rows, "3", []alignment{alignLeft, alignLeft, alignRight}, 0); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTabulifyAlignment(t *testing.T) {
+	rows := [][]string{
+		{"Profile", "Code", "Life Time"},
+		{"github", "492039", "5 (s)"},
+		{"bank", "12345678", "25 (s)"},
+	}
+	align := []alignment{alignLeft, alignRight, alignRight}
+	tests := []struct {
+		style, want string
+	}{
+		{"0", "" +
+			" Profile      Code  Life Time \n" +
+			" github     492039      5 (s) \n" +
+			" bank     12345678     25 (s) "},
+		{"1", "" +
+			" Profile      Code  Life Time \n" +
+			" -------  --------  --------- \n" +
+			" github     492039      5 (s) \n" +
+			" bank     12345678     25 (s) "},
+		{"2", "" +
+			"+---------+----------+-----------+\n" +
+			"| Profile |     Code | Life Time |\n" +
+			"+---------+----------+-----------+\n" +
+			"| github  |   492039 |     5 (s) |\n" +
+			"+---------+----------+-----------+\n" +
+			"| bank    | 12345678 |    25 (s) |\n" +
+			"+---------+----------+-----------+"},
+	}
+	for _, test := range tests {
+		if got := tabulify(rows, test.style, align, 0); got != test.want {
+			t.Errorf("style %s: got\n%s\nwant\n%s", test.style, got, test.want)
+		}
+	}
+}
+
+func TestTabulifyEmpty(t *testing.T) {
+	for _, style := range []string{"0", "1", "2", "3"} {
+		for _, rows := range [][][]string{nil, {}, {{}}, {{}, {}}} {
+			if got := tabulify(rows, style, nil, 0); got != "" {
+				t.Errorf("style %s, rows %q: got %q, want nothing", style, rows, got)
+			}
+		}
+	}
+}
+
+func TestTabulifySingleColumn(t *testing.T) {
+	rows := [][]string{{"Code"}, {"492039"}}
+	tests := []struct {
+		style, want string
+	}{
+		{"0", " Code   \n 492039 "},
+		{"1", " Code   \n ------ \n 492039 "},
+		{"2", "+--------+\n| Code   |\n+--------+\n| 492039 |\n+--------+"},
+		{"3", "| Code   |\n|--------|\n| 492039 |"},
+	}
+	for _, test := range tests {
+		if got := tabulify(rows, test.style, nil, 0); got != test.want {
+			t.Errorf("style %s: got\n%s\nwant\n%s", test.style, got, test.want)
+		}
+	}
+}
+
+// TestTabulifyStyle2ZeroRows checks that a table of only a header is
+// drawn once, in a box, without the plain table before it.
+func TestTabulifyStyle2ZeroRows(t *testing.T) {
+	rows := [][]string{{"Profile", "Code"}}
+	want := "" +
+		"+---------+------+\n" +
+		"| Profile | Code |\n" +
+		"+---------+------+"
+	if got := tabulify(rows, "2", nil, 0); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTabulifyMaxWidth(t *testing.T) {
+	rows := [][]string{
+		{"Profile", "Domain", "Code"},
+		{"personal-github", "accounts.example.com", "492039"},
+	}
+	align := []alignment{alignLeft, alignLeft, alignRight}
+	// The 21 cells left by the borders and the code are shared evenly.
+	want := "" +
+		"+------------+------------+--------+\n" +
+		"| Profile    | Domain     |   Code |\n" +
+		"+------------+------------+--------+\n" +
+		"| personal-… | accounts.… | 492039 |\n" +
+		"+------------+------------+--------+"
+	got := tabulify(rows, "2", align, 37)
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	for _, line := range strings.Split(got, "\n") {
+		if w := displayWidth(line); w > 37 {
+			t.Errorf("line %q is %d cells wide", line, w)
+		}
+	}
+	if got := tabulify(rows, "0", align, 200); got != tabulify(rows, "0", align, 0) {
+		t.Errorf("a wide terminal truncated the table:\n%s", got)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/BurntSushi/toml"
+)
+
+// tomlFile is the layout of a TOML secrets file: one [accounts.<name>]
+// table per account.
+type tomlFile struct {
+	Accounts map[string]fileAccount `toml:"accounts"`
+}
+
+// parseTOML parses the text of a TOML secrets file into INI sections
+// and returns the account names in file order.
+func parseTOML(content []byte) (map[string]map[string]string, []string, error) {
+	var file tomlFile
+	md, err := toml.Decode(string(content), &file)
+	if err != nil {
+		return nil, nil, err
+	}
+	var order []string
+	for _, key := range md.Keys() {
+		if len(key) == 2 && key[0] == "accounts" {
+			order = append(order, key[1])
+		}
+	}
+	return sections(file.Accounts), order, nil
+}
+
+func encodeTOML(accounts map[string]fileAccount) ([]byte, error) {
+	var b bytes.Buffer
+	enc := toml.NewEncoder(&b)
+	enc.Indent = ""
+	if err := enc.Encode(tomlFile{Accounts: accounts}); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
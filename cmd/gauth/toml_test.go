@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// roundTripConfig is a secrets file using every key of fileAccount.
+var roundTripConfig = map[string]map[string]string{
+	"github": {"secret": "JBSWY3DPEHPK3PXP", "user": "alice", "domain": "github.com"},
+	"bank": {
+		"secret": "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", "algorithm": "SHA256",
+		"digits": "8", "period": "60", pinPrefixKey: "12", pinSuffixKey: "34",
+	},
+	"steam": {"secret": "JBSWY3DPEHPK3PXP", "format": "steam"},
+	"token": {
+		"secret": "JBSWY3DPEHPK3PXP", "type": "hotp", "counter": "42",
+		counterUpdatedKey: "2026-01-02T03:04:05Z",
+	},
+}
+
+func TestTOMLRoundTrip(t *testing.T) {
+	accounts, err := fileAccounts(roundTripConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := encodeTOML(accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, order, err := parseTOML(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, roundTripConfig) {
+		t.Errorf("round trip of\n%s\ngave %v", content, config)
+	}
+	slices.Sort(order)
+	if want := []string{"bank", "github", "steam", "token"}; !slices.Equal(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+func TestParseTOMLOrder(t *testing.T) {
+	_, order, err := parseTOML([]byte("[accounts.zeta]\nsecret = \"JBSWY3DPEHPK3PXP\"\n\n[accounts.alpha]\nsecret = \"JBSWY3DPEHPK3PXP\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"zeta", "alpha"}; !slices.Equal(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
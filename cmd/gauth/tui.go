@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"golang.org/x/term"
+)
+
+// useTUI reports whether --list --continue shows its table in the
+// full-screen view of listTUI rather than printing it over and over.
+func useTUI(opts listOptions) bool {
+	return opts.Continue && !opts.Once && opts.Format == "table" &&
+		term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// tableLine returns the line of a table of the given style drawn by
+// tabulify that holds row, where row 0 is the header.
+func tableLine(style string, row int) int {
+	switch {
+	case style == "2":
+		return 1 + 2*row
+	case row > 0 && (style == "1" || style == "3"):
+		return row + 1
+	}
+	return row
+}
+
+// listTUI shows the codes of table on the alternate screen of the
+// terminal, refreshed in place every opts.Interval, or when the codes
+// change with opts.Align, until q is pressed. / searches the accounts
+// like --search, c copies the code of the selected account.
+func listTUI(table []account, opts listOptions) error {
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		return err
+	}
+	if err := screen.Init(); err != nil {
+		return err
+	}
+	defer screen.Fini()
+
+	var watched os.FileInfo
+	if opts.Reload != nil {
+		watched, _ = os.Stat(opts.Watch)
+	}
+	query, searching, selected, status := "", false, 0, ""
+	draw := func() {
+		now := time.Now()
+		shown := table
+		if query != "" {
+			shown = filterAccounts(table, query)
+		}
+		selected = max(min(selected, len(shown)-1), 0)
+		width, height := screen.Size()
+		rows, align := tableRows(shown, codeRows(shown, opts, now), opts, false)
+		lines := strings.Split(tabulify(rows, opts.Style, align, width), "\n")
+
+		screen.Clear()
+		for y, line := range lines {
+			style := tcell.StyleDefault
+			if len(shown) > 0 && y == tableLine(opts.Style, selected+1) {
+				style = style.Reverse(true)
+			}
+			screen.PutStrStyled(0, y, line, style)
+		}
+		y := len(lines)
+		if cfg, ok := refreshConfig(shown); ok {
+			screen.PutStr(0, y, refreshBar(cfg, now, width))
+			y++
+		}
+		help := "q quit  / search  c copy  ↑↓ select"
+		if searching {
+			help = "/" + query
+		} else if query != "" {
+			help = fmt.Sprintf("search: %s (Esc to clear)  %s", query, help)
+		}
+		screen.PutStr(0, min(y+1, height-2), help)
+		screen.PutStr(0, min(y+2, height-1), status)
+		screen.Show()
+	}
+	copySelected := func() {
+		shown := table
+		if query != "" {
+			shown = filterAccounts(table, query)
+		}
+		if selected >= len(shown) {
+			return
+		}
+		acct := shown[selected]
+		code, err := acct.code(time.Now())
+		if err == nil {
+			err = copyToClipboard(code)
+		}
+		if err != nil {
+			status = "can not copy code: " + err.Error()
+			return
+		}
+		status = fmt.Sprintf("copied code of [%s]", acct.Name)
+	}
+
+	events, quit := make(chan tcell.Event), make(chan struct{})
+	defer close(quit)
+	go func() {
+		// PollEvent returns nil once the screen is finalized.
+		for ev := screen.PollEvent(); ev != nil; ev = screen.PollEvent() {
+			select {
+			case events <- ev:
+			case <-quit:
+				return
+			}
+		}
+	}()
+	refresh := time.NewTimer(refreshWait(table, opts, time.Now()))
+	defer refresh.Stop()
+	for {
+		draw()
+		select {
+		case <-refresh.C:
+			refresh.Reset(refreshWait(table, opts, time.Now()))
+			if opts.Reload == nil {
+				continue
+			}
+			if info, err := os.Stat(opts.Watch); err == nil && fileChanged(watched, info) {
+				watched = info
+				if reloaded, err := opts.Reload(); err != nil {
+					status = "can not reload: " + err.Error()
+				} else {
+					table = reloaded
+					status = "[reloaded]"
+				}
+			}
+		case ev := <-events:
+			key, ok := ev.(*tcell.EventKey)
+			if !ok {
+				if _, ok := ev.(*tcell.EventResize); ok {
+					screen.Sync()
+				}
+				continue
+			}
+			switch {
+			case searching && key.Key() == tcell.KeyEnter:
+				searching = false
+			case searching && key.Key() == tcell.KeyEsc:
+				searching, query = false, ""
+			case searching && (key.Key() == tcell.KeyBackspace || key.Key() == tcell.KeyBackspace2):
+				if query != "" {
+					runes := []rune(query)
+					query = string(runes[:len(runes)-1])
+				}
+			case searching && key.Key() == tcell.KeyRune:
+				query += string(key.Rune())
+				selected = 0
+			case key.Key() == tcell.KeyCtrlC:
+				return nil
+			case key.Key() == tcell.KeyEsc:
+				query = ""
+			case key.Key() == tcell.KeyUp:
+				selected = max(selected-1, 0)
+			case key.Key() == tcell.KeyDown:
+				selected++
+			case key.Key() == tcell.KeyRune:
+				switch key.Rune() {
+				case 'q':
+					return nil
+				case '/':
+					searching, status = true, ""
+				case 'c':
+					copySelected()
+				case 'k':
+					selected = max(selected-1, 0)
+				case 'j':
+					selected++
+				}
+			}
+		}
+	}
+}
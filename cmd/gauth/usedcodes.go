@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gauth"
+)
+
+// usedCodesFileName is the name of the --verify state file in
+// configDir.
+const usedCodesFileName = "used-codes.json"
+
+// usedCode is the highest time step, or counter value, accepted for an
+// account. State files of older versions also hold the code, which is
+// ignored.
+type usedCode struct {
+	Step uint64 `json:"step"`
+}
+
+// defaultUsedCodesFile returns the state file used when --state-file is
+// not given, creating its directory when missing.
+func defaultUsedCodesFile() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, usedCodesFileName), nil
+}
+
+// usedCodeKey identifies an account in the state file: by label when
+// one is given, or else by a hash of its secret, which is never stored.
+func usedCodeKey(label, secret string) string {
+	if label != "" {
+		return label
+	}
+	return gauth.ReplayKey(secret)
+}
+
+// usedCodesFile is a gauth.ReplayStore kept in a JSON state file, so
+// that separate gauth processes refuse each other's codes.
+type usedCodesFile string
+
+// Accept implements gauth.ReplayStore, holding a lock on the file from
+// reading it to writing it back.
+func (filename usedCodesFile) Accept(key string, step uint64) error {
+	unlock, err := lockFile(string(filename))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	used := make(map[string]usedCode)
+	content, err := os.ReadFile(string(filename))
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(content, &used); err != nil {
+			return err
+		}
+	}
+	if last, ok := used[key]; ok && step <= last.Step {
+		return gauth.ErrCodeReused
+	}
+	used[key] = usedCode{Step: step}
+	content, err = json.MarshalIndent(used, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(string(filename), append(content, '\n'))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gauth"
+)
+
+func TestUsedCodesFile(t *testing.T) {
+	store := usedCodesFile(filepath.Join(t.TempDir(), "used-codes.json"))
+	steps := []struct {
+		key  string
+		step uint64
+		want error
+	}{
+		{"github", 100, nil},
+		{"github", 100, gauth.ErrCodeReused},
+		{"github", 99, gauth.ErrCodeReused},
+		{"work", 99, nil},
+		{"github", 101, nil},
+	}
+	for _, s := range steps {
+		if err := store.Accept(s.key, s.step); err != s.want {
+			t.Errorf("Accept(%s, %d) = %v, want %v", s.key, s.step, err, s.want)
+		}
+	}
+	if _, err := os.Stat(string(store) + ".lock"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
+
+func TestUsedCodesFileOldFormat(t *testing.T) {
+	filename := writeTemp(t, "used-codes.json", `{"github": {"code": "123456", "step": 100}}`)
+	store := usedCodesFile(filename)
+	if err := store.Accept("github", 99); err != gauth.ErrCodeReused {
+		t.Errorf("Accept of an earlier step = %v, want ErrCodeReused", err)
+	}
+	if err := store.Accept("github", 101); err != nil {
+		t.Errorf("Accept of a later step: %v", err)
+	}
+}
+
+// TestUsedCodesFileConcurrent verifies one code from many goroutines:
+// the lock lets exactly one of them accept it.
+func TestUsedCodesFileConcurrent(t *testing.T) {
+	store := usedCodesFile(filepath.Join(t.TempDir(), "used-codes.json"))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.Accept("github", 100)
+			if err != nil && err != gauth.ErrCodeReused {
+				t.Error(err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				accepted++
+			}
+		}()
+	}
+	wg.Wait()
+	if accepted != 1 {
+		t.Errorf("the code was accepted %d times", accepted)
+	}
+}
+
+func TestLockFileStale(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "used-codes.json")
+	lock := writeTemp(t, "unused", "")
+	if err := os.Rename(lock, filename+".lock"); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filename+".lock", old, old); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockFile(filename)
+	if err != nil {
+		t.Fatalf("a stale lock was not taken over: %v", err)
+	}
+	unlock()
+}
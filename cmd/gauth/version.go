@@ -0,0 +1,54 @@
+package main
+
+import (
+	"runtime/debug"
+)
+
+// version, commit and buildDate are set at link time by build.sh with
+// -ldflags "-X main.version=...". Builds without them fall back to the
+// information the go command embeds in the binary.
+var (
+	version   string
+	commit    string
+	buildDate string
+)
+
+// versionInfo is printed by --version.
+type versionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+func currentVersion() versionInfo {
+	info := versionInfo{Version: version, Commit: commit, BuildDate: buildDate}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		info.GoVersion = build.GoVersion
+		if info.Version == "" && build.Main.Version != "(devel)" {
+			info.Version = build.Main.Version
+		}
+		modified := false
+		for _, setting := range build.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if modified && commit == "" && info.Commit != "" {
+			info.Commit += "-dirty"
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	return info
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"gauth"
+)
+
+// progressBar draws a bar of width cells, filled to fraction.
+func progressBar(fraction float64, width int) string {
+	return drawBar(fraction, width, "=", " ")
+}
+
+func drawBar(fraction float64, width int, full, empty string) string {
+	filled := int(fraction*float64(width) + 0.5)
+	filled = min(filled, width)
+	if filled < 0 {
+		filled = 0
+	}
+	return "[" + strings.Repeat(full, filled) + strings.Repeat(empty, width-filled) + "]"
+}
+
+// refreshBar shows how much of the current period has passed and the
+// seconds left until codes refresh. On a terminal of the given width the
+// bar spans it; with a width of 0 a short ASCII bar is drawn.
+func refreshBar(cfg gauth.Config, now time.Time, width int) string {
+	life := cfg.Expiry(now).Unix() - now.Unix()
+	elapsed := float64(int64(cfg.Period)-life) / float64(cfg.Period)
+	suffix := fmt.Sprintf(" %2ds", life)
+	if width <= 0 {
+		return progressBar(elapsed, 20) + suffix
+	}
+	width = max(width-len(suffix)-2, 10)
+	return drawBar(elapsed, width, "█", "░") + suffix
+}
+
+// watchCode keeps rewriting a single line with the current code, with
+// the PIN p around it, and the time it has left until interrupted.
+func watchCode(cfg gauth.Config, secret string, p pin) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		code, err := cfg.GenerateCode(secret, cfg.TimeStep(now))
+		if err != nil {
+			return err
+		}
+		life := cfg.Expiry(now).Unix() - now.Unix()
+		bar := progressBar(float64(life)/float64(cfg.Period), 20)
+		fmt.Printf("\r%s %s %2ds\x1b[K", p.wrap(code), bar, life)
+
+		select {
+		case <-interrupt:
+			fmt.Println()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlFile is the layout of a YAML secrets file: a top-level accounts
+// map keyed by account name.
+type yamlFile struct {
+	Accounts map[string]fileAccount `yaml:"accounts"`
+}
+
+// parseYAML parses the text of a YAML secrets file into INI sections
+// and returns the account names in file order.
+func parseYAML(content []byte) (map[string]map[string]string, []string, error) {
+	var file yamlFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, nil, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return nil, nil, err
+	}
+	return sections(file.Accounts), yamlAccountNames(&root), nil
+}
+
+// yamlAccountNames returns the keys of the accounts map in document
+// order.
+func yamlAccountNames(root *yaml.Node) []string {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil
+	}
+	top := root.Content[0]
+	if top.Kind != yaml.MappingNode {
+		return nil
+	}
+	var names []string
+	for i := 0; i+1 < len(top.Content); i += 2 {
+		if top.Content[i].Value != "accounts" || top.Content[i+1].Kind != yaml.MappingNode {
+			continue
+		}
+		accounts := top.Content[i+1]
+		for j := 0; j+1 < len(accounts.Content); j += 2 {
+			names = append(names, accounts.Content[j].Value)
+		}
+	}
+	return names
+}
+
+func encodeYAML(accounts map[string]fileAccount) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("---\n")
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(yamlFile{Accounts: accounts}); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
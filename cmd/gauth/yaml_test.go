@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestYAMLRoundTrip(t *testing.T) {
+	accounts, err := fileAccounts(roundTripConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := encodeYAML(accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, order, err := parseYAML(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, roundTripConfig) {
+		t.Errorf("round trip of\n%s\ngave %v", content, config)
+	}
+	slices.Sort(order)
+	if want := []string{"bank", "github", "steam", "token"}; !slices.Equal(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
+
+// FuzzYAMLRoundTrip checks that names and values YAML would take for
+// other types, or needs to quote, survive encoding and parsing. Line
+// breaks are left out as INI secrets files can not hold them either.
+func FuzzYAMLRoundTrip(f *testing.F) {
+	f.Add("github", "alice", "github.com")
+	f.Add("yes", "no", "null")
+	f.Add("0x1F", "1e3", "~")
+	f.Add("- item", "key: value", "# comment")
+	f.Add("[brackets]", " padded ", "\"quoted'")
+	f.Add("ünïcødé", "ユーザー", "例え.jp")
+	f.Fuzz(func(t *testing.T, name, user, domain string) {
+		for _, s := range []string{name, user, domain} {
+			if !utf8.ValidString(s) || strings.ContainsAny(s, "\r\n") {
+				t.Skip()
+			}
+		}
+		want := map[string]map[string]string{
+			name: {"secret": "JBSWY3DPEHPK3PXP", "user": user, "domain": domain},
+		}
+		if user == "" {
+			delete(want[name], "user")
+		}
+		if domain == "" {
+			delete(want[name], "domain")
+		}
+		accounts, err := fileAccounts(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := encodeYAML(accounts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, order, err := parseYAML(content)
+		if err != nil {
+			t.Fatalf("parsing\n%s\nfailed: %v", content, err)
+		}
+		if !reflect.DeepEqual(config, want) {
+			t.Errorf("round trip of\n%s\ngave %q, want %q", content, config, want)
+		}
+		if !slices.Equal(order, []string{name}) {
+			t.Errorf("order = %q, want %q", order, name)
+		}
+	})
+}
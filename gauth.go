@@ -1,383 +1,475 @@
-package main
+// Package gauth implements the HOTP (RFC 4226) and TOTP (RFC 6238)
+// one-time password algorithms used by Google Authenticator.
+package gauth
 
 import (
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha1"
+	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/base32"
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"os"
-	"sort"
+	"hash"
+	neturl "net/url"
 	"strings"
 	"time"
 )
 
-func main() {
-	args := os.Args
-	if len(args) <= 1 {
-		fmt.Println("usage: gauth <operation> [...]")
-		fmt.Println("operations:")
-		fmt.Println("    gauth {-c --create} [user] [domain]")
-		fmt.Println("    gauth {-v --verify} secret code")
-		fmt.Println("    gauth {-d --display} secret")
-		fmt.Println("    gauth {-l --list} filename [--continue]")
-		return
-	}
-
-	cmd := args[1]
-	switch cmd {
-	case "-c", "--create":
-		key := generateSecretKey()
-		fmt.Println("secret:", key)
-		user := ""
-		domain := ""
-		if len(args) > 2 {
-			user = args[2]
-		}
-		if len(args) > 3 {
-			domain = args[3]
-		}
-		otpAuthURL := getOTPAuthURL(user, domain, key)
-		fmt.Println("url:", otpAuthURL)
-		barcodeURL := getBarcodeURL(user, domain, key)
-		fmt.Println("barcode:", barcodeURL)
-
-	case "-v", "--verify":
-		if len(args) < 4 {
-			fmt.Println("require secret and code parameters")
-			return
-		}
-		secret := args[2]
-		code := args[3]
-		if verifyTimeBased(secret, code, 3) == -1 {
-			fmt.Println("verification failed")
-			return
-		}
-		fmt.Println("verification succeeded")
+var (
+	// ErrInvalidSecret is returned when a secret is not valid base32.
+	ErrInvalidSecret = errors.New("gauth: invalid secret")
+	// ErrInvalidCode is returned when a code to verify is not made of
+	// the configured number of digits.
+	ErrInvalidCode = errors.New("gauth: invalid code")
+	// ErrFileNotFound is returned when a secrets file does not exist.
+	ErrFileNotFound = errors.New("gauth: file not found")
+
+	// ErrEmptySecret is returned by ValidateSecret for a secret
+	// without any characters other than spaces.
+	ErrEmptySecret = fmt.Errorf("%w: empty secret", ErrInvalidSecret)
+	// ErrPaddingRequired is returned by ValidateSecret for a secret
+	// padded with "=" other than to the next multiple of eight
+	// characters.
+	ErrPaddingRequired = fmt.Errorf("%w: incomplete padding", ErrInvalidSecret)
+)
 
-	case "-d", "--display":
-		if len(args) < 3 {
-			fmt.Println("require secret parameter")
-			return
-		}
-		secret := args[2]
-		code := generateCode(secret, nil)
-		fmt.Println(code)
-
-	case "-l", "--list":
-		if len(args) < 3 {
-			fmt.Println("require file name")
-			return
-		}
-		filename := args[2]
-		if strings.Contains(filename, "~") {
-			homeDir, _ := os.UserHomeDir()
-			filename = strings.Replace(filename, "~", homeDir, 1)
-		}
-		if _, err := os.Stat(filename); os.IsNotExist(err) {
-			fmt.Printf("can not read: %s\n", filename)
-			return
-		}
-		cont := false
-		if len(args) >= 4 {
-			if args[3] == "-" || args[3] == "-c" || args[3] == "--continue" {
-				cont = true
-			}
-		}
-		config := loadINI(filename)
-		keys := make([]string, 0, len(config))
-		for key := range config {
-			keys = append(keys, key)
-		}
-		sort.Strings(keys)
-		table := make([][]string, 0)
-		for _, key := range keys {
-			cfg := config[key]
-			if cfg == nil {
-				continue
-			}
-			secret := cfg["secret"]
-			user := cfg["user"]
-			domain := cfg["domain"]
-			table = append(table, []string{secret, user, domain})
-		}
-		listCode(table, cont)
+// ErrInvalidCharacter is returned by ValidateSecret for a character that
+// is not in the base32 alphabet A-Z, 2-7. Index is the byte offset of
+// Char in the secret.
+type ErrInvalidCharacter struct {
+	Char  rune
+	Index int
+}
 
-	default:
-		fmt.Println("unknown operation")
+func (e ErrInvalidCharacter) Error() string {
+	return fmt.Sprintf("%v: invalid character %q at index %d", ErrInvalidSecret, e.Char, e.Index)
+}
+
+func (e ErrInvalidCharacter) Unwrap() error { return ErrInvalidSecret }
+
+// ErrInvalidLength is returned by ValidateSecret for a secret of Got
+// characters, without spaces and padding, that no whole number of bytes
+// encodes to: Got modulo RequiredMod is 1, 3 or 6.
+type ErrInvalidLength struct {
+	Got         int
+	RequiredMod int
+}
+
+func (e ErrInvalidLength) Error() string {
+	return fmt.Sprintf("%v: invalid length %d", ErrInvalidSecret, e.Got)
+}
+
+func (e ErrInvalidLength) Unwrap() error { return ErrInvalidSecret }
+
+// Algorithm names the HMAC hash function used to compute codes.
+type Algorithm string
+
+const (
+	SHA1   Algorithm = "SHA1"
+	SHA256 Algorithm = "SHA256"
+	SHA512 Algorithm = "SHA512"
+)
+
+// Format is the way a code is written.
+type Format string
+
+const (
+	// Numeric codes are Config.Digits decimal digits, as RFC 4226
+	// specifies.
+	Numeric Format = "numeric"
+	// Steam codes are five characters of steamAlphabet, as shown by
+	// Steam Guard. Config.Digits does not apply to them.
+	Steam Format = "steam"
+	// Blizzard codes are those of the Battle.net Authenticator: eight
+	// decimal digits, whatever Config.Digits says, truncated from the
+	// HMAC as RFC 4226 specifies.
+	Blizzard Format = "blizzard"
+)
+
+// blizzardDigits is the number of digits of Battle.net codes.
+const blizzardDigits = 8
+
+// steamAlphabet holds the characters of Steam Guard codes.
+const steamAlphabet = "23456789BCDFGHJKMNPQRTVWXY"
+
+// steamLength is the number of characters of a Steam Guard code.
+const steamLength = 5
+
+// ParseFormat returns the Format named by s, ignoring case.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(s)); f {
+	case Numeric, Steam, Blizzard:
+		return f, nil
 	}
+	return "", fmt.Errorf("gauth: unsupported format %q", s)
+}
+
+// Config captures the parameters shared by an issuer and the
+// authenticator app. The zero value is usable and behaves like
+// DefaultConfig, except that Verify accepts the current time step only.
+type Config struct {
+	Issuer    string
+	Algorithm Algorithm
+	Digits    int
+	Period    uint
+	// Format is the way codes are written. The zero value is Numeric.
+	Format Format
+	// Window is the number of time steps before and after the current
+	// one that Verify accepts, to allow for clock drift.
+	Window int
+	// TimeFunc returns the current time. When nil, time.Now is used.
+	TimeFunc func() time.Time
+	// Replay, when set, makes Client.Verify refuse codes of time steps
+	// no later than one it accepted before for the same secret.
+	Replay ReplayStore
+}
+
+// DefaultConfig is the configuration used by Google Authenticator:
+// HMAC-SHA1, six digits and a 30 second period. Verify accepts codes
+// one time step off.
+var DefaultConfig = Config{
+	Algorithm: SHA1,
+	Digits:    6,
+	Period:    30,
+	Window:    1,
 }
 
-func generateSecretKey() string {
-	const length = 16
-	byteHash := generateRandomBytes()
-	if length > 102 {
-		byteRand := generateRandomBytes()
-		byteHash = append(byteHash, byteRand...)
+// ParseAlgorithm returns the Algorithm named by s, ignoring case.
+func ParseAlgorithm(s string) (Algorithm, error) {
+	a := Algorithm(strings.ToUpper(s))
+	if _, err := a.hash(); err != nil {
+		return "", err
 	}
-	text := base32.StdEncoding.EncodeToString(byteHash)[:length]
-	return text
+	return a, nil
 }
 
-func generateRandomBytes() []byte {
-	shaHash := sha512.New()
-	shaHash.Write(make([]byte, 8192))
-	byteHash := shaHash.Sum(nil)
+func (a Algorithm) hash() (func() hash.Hash, error) {
+	switch a {
+	case "", SHA1:
+		return sha1.New, nil
+	case SHA256:
+		return sha256.New, nil
+	case SHA512:
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("gauth: unsupported algorithm %q", string(a))
+}
 
-	for i := 0; i < 6; i++ {
-		shaHash = sha512.New()
-		shaHash.Write(byteHash)
-		byteHash = shaHash.Sum(nil)
+func (c Config) digits() (int, error) {
+	switch {
+	case c.Format == Steam:
+		return steamLength, nil
+	case c.Format == Blizzard:
+		return blizzardDigits, nil
+	case c.Digits == 0:
+		return DefaultConfig.Digits, nil
+	case c.Digits < 6 || c.Digits > 8:
+		return 0, fmt.Errorf("gauth: unsupported number of digits %d", c.Digits)
 	}
+	return c.Digits, nil
+}
 
-	return byteHash
+func (c Config) period() uint {
+	if c.Period == 0 {
+		return DefaultConfig.Period
+	}
+	return c.Period
 }
 
-func getOTPAuthURL(user, domain, secret string) string {
-	return fmt.Sprintf("otpauth://totp/%s@%s?secret=%s", user, domain, secret)
+// GenerateSecretKey returns a new random base32 encoded secret of 16
+// characters, holding 80 bits.
+func GenerateSecretKey() (string, error) {
+	return GenerateSecretKeyN(80)
 }
 
-func getBarcodeURL(user, domain, secret string) string {
-	optURL := getOTPAuthURL(user, domain, secret)
-	url := "https://www.google.com/chart?chs=200x200&chld=M|0&cht=qr&chl=" + optURL
-	return url
+// GenerateSecretKeyN returns a new random base32 encoded secret holding
+// at least bits bits. bits must be a multiple of 5, the bits of a base32
+// character, and at least 80. The key is rounded up to whole bytes and
+// encoded without padding: 160 bits, as RFC 4226 recommends for SHA1,
+// give 32 characters and 255 bits give 52.
+func GenerateSecretKeyN(bits int) (string, error) {
+	if bits%5 != 0 || bits < 80 {
+		return "", fmt.Errorf("gauth: invalid secret length of %d bits, must be a multiple of 5 and at least 80", bits)
+	}
+	byteHash, err := generateRandomBytes((bits + 7) / 8)
+	if err != nil {
+		return "", err
+	}
+	text := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(byteHash)
+	return text, nil
 }
 
-func generateCode(secret string, value []byte) string {
-	if value == nil {
-		value = make([]byte, 8)
-		binary.BigEndian.PutUint64(value, uint64(time.Now().Unix()/30))
+func generateRandomBytes(n int) ([]byte, error) {
+	byteHash := make([]byte, n)
+	if _, err := rand.Read(byteHash); err != nil {
+		return nil, err
 	}
+	return byteHash, nil
+}
 
-	token := strings.ReplaceAll(secret, " ", "")
-	decodedSecret, _ := base32.StdEncoding.DecodeString(token)
+// OTPAuthURL returns the otpauth:// key URI understood by authenticator apps.
+func (c Config) OTPAuthURL(user, domain, secret string) string {
+	return c.keyURI("totp", user, domain, secret, "")
+}
 
-	hash := hmac.New(sha1.New, decodedSecret)
-	hash.Write(value)
-	hashResult := hash.Sum(nil)
+// HOTPAuthURL returns the otpauth:// key URI of a counter-based account
+// whose next code is generated for counter.
+func (c Config) HOTPAuthURL(user, domain, secret string, counter uint64) string {
+	return c.keyURI("hotp", user, domain, secret, fmt.Sprintf("&counter=%d", counter))
+}
 
-	offset := int(hashResult[len(hashResult)-1]) & 0xf
-	truncatedHash := hashResult[offset : offset+4]
+func (c Config) keyURI(otpType, user, domain, secret, extra string) string {
+	url := fmt.Sprintf("otpauth://%s/%s@%s?secret=%s", otpType, escapeLabel(user), escapeLabel(domain), neturl.QueryEscape(secret))
+	if c.Algorithm != "" && c.Algorithm != SHA1 {
+		url += "&algorithm=" + string(c.Algorithm)
+	}
+	if c.Digits != 0 && c.Digits != DefaultConfig.Digits {
+		url += fmt.Sprintf("&digits=%d", c.Digits)
+	}
+	if otpType == "totp" && c.Period != 0 && c.Period != DefaultConfig.Period {
+		url += fmt.Sprintf("&period=%d", c.Period)
+	}
+	url += extra
+	if c.Issuer != "" {
+		url += "&issuer=" + neturl.QueryEscape(c.Issuer)
+	}
+	return url
+}
 
-	truncatedHashInt := binary.BigEndian.Uint32(truncatedHash)
-	truncatedHashInt &= 0x7fffffff
-	truncatedHashInt %= 1000000
+// escapeLabel escapes user or domain for the label of a key URI,
+// including the "@" and ":" that separate the parts of the label.
+func escapeLabel(s string) string {
+	return strings.NewReplacer("@", "%40", ":", "%3A").Replace(neturl.PathEscape(s))
+}
 
-	return fmt.Sprintf("%06d", truncatedHashInt)
+func (c Config) now() time.Time {
+	if c.TimeFunc == nil {
+		return time.Now()
+	}
+	return c.TimeFunc()
 }
 
-func verifyCounterBased(secret, code string, counter int, window int) int {
-	for offset := 1; offset <= window; offset++ {
-		value := make([]byte, 8)
-		binary.BigEndian.PutUint64(value, uint64(counter+offset))
-		validCode := generateCode(secret, value)
-		if code == validCode {
-			return counter + offset
+// TimeStep returns the TOTP counter value for t.
+func (c Config) TimeStep(t time.Time) uint64 {
+	return uint64(t.Unix()) / uint64(c.period())
+}
+
+// Expiry returns the time at which the code for t's time step expires.
+func (c Config) Expiry(t time.Time) time.Time {
+	return time.Unix(int64(c.TimeStep(t)+1)*int64(c.period()), 0)
+}
+
+// NormalizeSecret returns secret in canonical form: upper case, without
+// spaces and padded to a multiple of eight characters. Secrets are
+// accepted with or without padding; anything else that is not valid
+// base32 is an error.
+func NormalizeSecret(secret string) (string, error) {
+	decodedSecret, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(decodedSecret), nil
+}
+
+// ValidateSecret returns nil when secret can be decoded, or else an error
+// describing the first problem found: ErrEmptySecret, an
+// ErrInvalidCharacter, ErrPaddingRequired or an ErrInvalidLength. Like
+// the code generating functions, it accepts spaces, lower case letters
+// and secrets without padding.
+func ValidateSecret(secret string) error {
+	length, padding := 0, 0
+	for i, r := range secret {
+		switch {
+		case r == ' ':
+		case r == '=':
+			padding++
+		case padding > 0:
+			// Padding must come last.
+			return ErrInvalidCharacter{Char: '=', Index: strings.IndexByte(secret, '=')}
+		case 'A' <= r && r <= 'Z', 'a' <= r && r <= 'z', '2' <= r && r <= '7':
+			length++
+		default:
+			return ErrInvalidCharacter{Char: r, Index: i}
+		}
+	}
+	if length == 0 {
+		if padding > 0 {
+			return ErrInvalidCharacter{Char: '=', Index: strings.IndexByte(secret, '=')}
 		}
+		return ErrEmptySecret
+	}
+	switch length % 8 {
+	case 1, 3, 6:
+		return ErrInvalidLength{Got: length, RequiredMod: 8}
 	}
-	return -1
+	if padding > 0 && padding != (8-length%8)%8 {
+		return ErrPaddingRequired
+	}
+	return nil
 }
 
-func verifyTimeBased(secret, code string, window int) int {
-	epoch := time.Now().Unix() / 30
+// decodeSecret decodes a base32 secret, tolerating spaces, lower case
+// letters and missing padding as found in secrets copied from other apps.
+func decodeSecret(secret string) ([]byte, error) {
+	if err := ValidateSecret(secret); err != nil {
+		return nil, err
+	}
+	token := strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	decodedSecret, err := base32.StdEncoding.DecodeString(token)
+	if err != nil {
+		decodedSecret, err = base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(token)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSecret, err)
+	}
+	return decodedSecret, nil
+}
 
-	for offset := -(window / 2); offset < window-(window/2); offset++ {
-		value := make([]byte, 8)
-		binary.BigEndian.PutUint64(value, uint64(epoch)+uint64(offset))
-		validCode := generateCode(secret, value)
-		if code == validCode {
-			return int(epoch) + offset
+// checkCode reports ErrInvalidCode unless code could have been returned
+// by GenerateCode.
+func (c Config) checkCode(code string) error {
+	if c.Format == Steam {
+		if len(code) != steamLength || strings.Trim(code, steamAlphabet) != "" {
+			return fmt.Errorf("%w: %q is not a Steam Guard code", ErrInvalidCode, code)
 		}
+		return nil
 	}
-
-	return -1
+	digits, err := c.digits()
+	if err != nil {
+		return err
+	}
+	if len(code) != digits {
+		return fmt.Errorf("%w: want %d digits, got %q", ErrInvalidCode, digits, code)
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("%w: %q is not a number", ErrInvalidCode, code)
+		}
+	}
+	return nil
 }
 
-func loadINI(filename string) map[string]map[string]string {
-	config := make(map[string]map[string]string)
+// GenerateCode returns the code for the given counter value.
+func (c Config) GenerateCode(secret string, counter uint64) (string, error) {
+	hashFunc, err := c.Algorithm.hash()
+	if err != nil {
+		return "", err
+	}
+	digits, err := c.digits()
+	if err != nil {
+		return "", err
+	}
+
+	switch c.Format {
+	case "", Numeric, Steam, Blizzard:
+	default:
+		return "", fmt.Errorf("gauth: unsupported format %q", string(c.Format))
+	}
 
-	content, err := os.ReadFile(filename)
+	decodedSecret, err := decodeSecret(secret)
 	if err != nil {
-		return config
+		return "", err
 	}
+	return generateCode(hashFunc, decodedSecret, counter, digits, c.Format), nil
+}
 
-	text := string(content)
-	lines := strings.Split(text, "\n")
-	var section string
+// generateCode computes the RFC 4226 code of digits digits for counter
+// with the HMAC of hashFunc keyed with the decoded secret key. Steam
+// codes encode the same truncated hash in steamAlphabet instead.
+func generateCode(hashFunc func() hash.Hash, key []byte, counter uint64, digits int, format Format) string {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, counter)
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+	hash := hmac.New(hashFunc, key)
+	hash.Write(value)
+	hashResult := hash.Sum(nil)
 
-		if len(line) == 0 {
-			continue
-		}
+	offset := int(hashResult[len(hashResult)-1]) & 0xf
+	truncatedHash := hashResult[offset : offset+4]
+
+	truncatedHashInt := binary.BigEndian.Uint32(truncatedHash)
+	truncatedHashInt &= 0x7fffffff
 
-		if line[0] == '[' && line[len(line)-1] == ']' {
-			section = line[1 : len(line)-1]
-			config[section] = make(map[string]string)
-		} else {
-			parts := strings.SplitN(line, "=", 2)
-			if len(parts) == 2 {
-				key := strings.TrimSpace(parts[0])
-				value := strings.TrimSpace(parts[1])
-				config[section][key] = value
-			}
+	if format == Steam {
+		code := make([]byte, steamLength)
+		for i := range code {
+			code[i] = steamAlphabet[truncatedHashInt%uint32(len(steamAlphabet))]
+			truncatedHashInt /= uint32(len(steamAlphabet))
 		}
+		return string(code)
+	}
+
+	modulo := uint32(1)
+	for i := 0; i < digits; i++ {
+		modulo *= 10
 	}
+	truncatedHashInt %= modulo
 
-	return config
+	return fmt.Sprintf("%0*d", digits, truncatedHashInt)
 }
 
-func listCode(table [][]string, cont bool) int {
-	for {
-		current := int(time.Now().Unix())
-		epoch := current / 30
-		life := 30 - (current % 30)
-		rows := [][]string{{"User", "Domain", "Code", "Life Time"}}
-		for _, record := range table {
-			secret := record[0]
-			user := record[1]
-			domain := record[2]
-			value := make([]byte, 8)
-			binary.BigEndian.PutUint64(value, uint64(epoch))
-			code := generateCode(secret, value)
-			rows = append(rows, []string{user, domain, code, fmt.Sprintf("  %d (s)", life)})
-		}
+// GenerateTimeBased returns the code for the current time step.
+//
+// Deprecated: use Generate, or GenerateTimeBasedAt for a given time.
+func (c Config) GenerateTimeBased(secret string) (string, error) {
+	return c.GenerateTimeBasedAt(secret, c.now())
+}
 
-		var style string
-		if env, ok := os.LookupEnv("GOOGAUTH_STYLE"); ok {
-			style = env
-		} else {
-			style = "2"
-		}
-		fmt.Println(tabulify(rows, style))
-		if !cont {
-			break
-		}
-		fmt.Println("press Ctrl+C to break ...")
-		time.Sleep(1 * time.Second)
-	}
-	return 0
-}
-
-func tabulify(rows [][]string, style string) string {
-	colsize := make(map[int]int)
-	maxcol := 0
-	output := []string{}
-	if len(rows) == 0 {
-		return ""
-	}
-	for _, row := range rows {
-		maxcol = max(maxcol, len(row))
-		for col, text := range row {
-			text := text
-			size := len(text)
-			if _, ok := colsize[col]; !ok {
-				colsize[col] = size
-			} else {
-				colsize[col] = max(size, colsize[col])
-			}
-		}
+// GenerateTimeBasedAt returns the code for the time step of t.
+func (c Config) GenerateTimeBasedAt(secret string, t time.Time) (string, error) {
+	return c.GenerateCode(secret, c.TimeStep(t))
+}
+
+// VerifyCounterBased checks code against the window counter values
+// following counter and returns the matching counter, or -1. A
+// malformed code is reported as ErrInvalidCode.
+func (c Config) VerifyCounterBased(secret, code string, counter int, window int) (int, error) {
+	if err := c.checkCode(code); err != nil {
+		return -1, err
 	}
-	if maxcol <= 0 {
-		return ""
-	}
-
-	for y, _ := range rows {
-		line := ""
-		for x := 0; x < maxcol; x++ {
-			csize := colsize[x]
-			if y >= len(rows) {
-				line += strings.Repeat(" ", csize+2)
-			} else {
-				row := rows[y]
-				if x >= len(row) {
-					line += strings.Repeat(" ", csize+2)
-				} else {
-					text := row[x]
-					padding := 2 + csize - len(text)
-					pad1 := 1
-					pad2 := padding - pad1
-					line += strings.Repeat(" ", pad1) + text + strings.Repeat(" ", pad2)
-				}
-			}
-		}
-		output = append(output, line)
-	}
-
-	if style == "0" {
-		return strings.Join(output, "\n")
-	} else if style == "1" {
-		newrows := make([][]string, 0)
-		if len(rows) > 0 {
-			newrows = append(newrows, rows[:1]...)
-			head := []string{}
-			for i := 0; i < maxcol; i++ {
-				head = append(head, strings.Repeat("-", colsize[i]))
-			}
-			newrows = append(newrows, head)
-			newrows = append(newrows, rows[1:]...)
-		}
-		output = []string{}
-		for y, _ := range newrows {
-			line := ""
-			for x := 0; x < maxcol; x++ {
-				csize := colsize[x]
-				if y >= len(newrows) {
-					line += strings.Repeat(" ", csize+2)
-				} else {
-					row := newrows[y]
-					if x >= len(row) {
-						line += strings.Repeat(" ", csize+2)
-					} else {
-						text := row[x]
-						padding := 2 + csize - len(text)
-						pad1 := 1
-						pad2 := padding - pad1
-						line += strings.Repeat(" ", pad1) + text + strings.Repeat(" ", pad2)
-					}
-				}
-			}
-			output = append(output, line)
-		}
-		return strings.Join(output, "\n")
-	} else if style == "2" {
-		sep := "+"
-		for x := 0; x < maxcol; x++ {
-			sep += strings.Repeat("-", colsize[x]+2) + "+"
+	for offset := 1; offset <= window; offset++ {
+		validCode, err := c.GenerateCode(secret, uint64(counter+offset))
+		if err != nil {
+			return -1, err
 		}
-		output = append(output, sep)
-		for y, _ := range rows {
-			line := "|"
-			for x := 0; x < maxcol; x++ {
-				csize := colsize[x]
-				if y >= len(rows) {
-					line += strings.Repeat(" ", csize+2) + "|"
-				} else {
-					row := rows[y]
-					if x >= len(row) {
-						line += strings.Repeat(" ", csize+2) + "|"
-					} else {
-						text := row[x]
-						padding := 2 + csize - len(text)
-						pad1 := 1
-						pad2 := padding - pad1
-						line += strings.Repeat(" ", pad1) + text + strings.Repeat(" ", pad2) + "|"
-					}
-				}
-			}
-			output = append(output, line)
-			output = append(output, sep)
+		if code == validCode {
+			return counter + offset, nil
 		}
-		return strings.Join(output, "\n")
 	}
-	return ""
+	return -1, nil
 }
 
-func max(a, b int) int {
-	if a > b {
-		return a
+// VerifyTimeBased checks code against the current time step and the
+// window steps before and after it, 2*window+1 steps in total. On a
+// match it returns the offset of the matching step from the current
+// one, in -window..window, and ok set to true. A malformed code is
+// reported as ErrInvalidCode.
+//
+// Deprecated: use Verify, or VerifyTimeBasedAt for the matching offset.
+func (c Config) VerifyTimeBased(secret, code string, window int) (offset int, ok bool, err error) {
+	return c.VerifyTimeBasedAt(secret, code, window, c.now())
+}
+
+// VerifyTimeBasedAt is like VerifyTimeBased but checks code against the
+// time steps around t instead of the current time.
+func (c Config) VerifyTimeBasedAt(secret, code string, window int, t time.Time) (offset int, ok bool, err error) {
+	if err := c.checkCode(code); err != nil {
+		return 0, false, err
 	}
-	return b
+	epoch := c.TimeStep(t)
+
+	for offset := -window; offset <= window; offset++ {
+		validCode, err := c.GenerateCode(secret, epoch+uint64(offset))
+		if err != nil {
+			return 0, false, err
+		}
+		if code == validCode {
+			return offset, true, nil
+		}
+	}
+
+	return 0, false, nil
 }
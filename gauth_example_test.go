@@ -0,0 +1,37 @@
+package gauth_test
+
+import (
+	"fmt"
+	"time"
+
+	"gauth"
+)
+
+// The secret of the RFC 6238 test vectors, "12345678901234567890".
+const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func ExampleClient_Generate() {
+	c := gauth.NewClient(gauth.WithDigits(8), gauth.WithTime(time.Unix(1111111109, 0)))
+	code, err := c.Generate(secret)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(code)
+	// Output: 07081804
+}
+
+func ExampleClient_Verify() {
+	// 94287082 is the code of the time step from 30 to 59 seconds.
+	for _, seconds := range []int64{59, 89, 119} {
+		c := gauth.NewClient(gauth.WithDigits(8), gauth.WithTime(time.Unix(seconds, 0)))
+		ok, err := c.Verify(secret, "94287082")
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(seconds, ok)
+	}
+	// Output:
+	// 59 true
+	// 89 true
+	// 119 false
+}
@@ -0,0 +1,455 @@
+package gauth
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"errors"
+	"hash"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateSecretKeyRandom(t *testing.T) {
+	first, err := GenerateSecretKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := GenerateSecretKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("two secrets are both %s", first)
+	}
+}
+
+// rfc4226Secret is the base32 encoding of the secret of the RFC 4226
+// and RFC 6238 test vectors, "12345678901234567890".
+const rfc4226Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateCode(t *testing.T) {
+	for counter, want := range []string{"755224", "287082", "359152"} {
+		code, err := DefaultConfig.GenerateCode(rfc4226Secret, uint64(counter))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != want {
+			t.Errorf("GenerateCode(%d) = %s, want %s", counter, code, want)
+		}
+	}
+	if _, err := DefaultConfig.GenerateCode("not base32!", 0); !errors.Is(err, ErrInvalidSecret) {
+		t.Errorf("GenerateCode with an invalid secret returned %v, want ErrInvalidSecret", err)
+	}
+}
+
+func TestTimeStepAndExpiry(t *testing.T) {
+	at := time.Unix(59, 0)
+	if got := DefaultConfig.TimeStep(at); got != 1 {
+		t.Errorf("TimeStep(59) = %d, want 1", got)
+	}
+	if got := DefaultConfig.Expiry(at); !got.Equal(time.Unix(60, 0)) {
+		t.Errorf("Expiry(59) = %v, want 60", got.Unix())
+	}
+}
+
+func TestGenerateTimeBasedAt(t *testing.T) {
+	code, err := DefaultConfig.GenerateTimeBasedAt(rfc4226Secret, time.Unix(59, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := DefaultConfig.GenerateCode(rfc4226Secret, 1)
+	if code != want {
+		t.Errorf("GenerateTimeBasedAt(59) = %s, want the code of step 1, %s", code, want)
+	}
+}
+
+func TestVerifyCounterBased(t *testing.T) {
+	code, _ := DefaultConfig.GenerateCode(rfc4226Secret, 3)
+	next, err := DefaultConfig.VerifyCounterBased(rfc4226Secret, code, 1, 3)
+	if err != nil || next != 3 {
+		t.Errorf("VerifyCounterBased = %d, %v, want 3", next, err)
+	}
+	next, err = DefaultConfig.VerifyCounterBased(rfc4226Secret, code, 3, 3)
+	if err != nil || next != -1 {
+		t.Errorf("VerifyCounterBased of a used code = %d, %v, want -1", next, err)
+	}
+	if _, err := DefaultConfig.VerifyCounterBased(rfc4226Secret, "12ab56", 0, 3); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("VerifyCounterBased of a malformed code returned %v, want ErrInvalidCode", err)
+	}
+}
+
+func TestVerifyTimeBasedAt(t *testing.T) {
+	at := time.Unix(1234567890, 0)
+	code, _ := DefaultConfig.GenerateTimeBasedAt(rfc4226Secret, at)
+	if _, ok, err := DefaultConfig.VerifyTimeBasedAt(rfc4226Secret, code, 0, at); err != nil || !ok {
+		t.Errorf("VerifyTimeBasedAt of the current code = %v, %v, want true", ok, err)
+	}
+	if _, ok, err := DefaultConfig.VerifyTimeBasedAt(rfc4226Secret, code, 0, at.Add(time.Hour)); err != nil || ok {
+		t.Errorf("VerifyTimeBasedAt an hour later = %v, %v, want false", ok, err)
+	}
+}
+
+func TestOTPAuthURL(t *testing.T) {
+	cfg := Config{Issuer: "Example", Algorithm: SHA256, Digits: 8, Period: 60}
+	tests := []struct {
+		got, want string
+	}{
+		{DefaultConfig.OTPAuthURL("alice", "example.com", "JBSWY3DPEHPK3PXP"),
+			"otpauth://totp/alice@example.com?secret=JBSWY3DPEHPK3PXP"},
+		{cfg.OTPAuthURL("alice", "example.com", "JBSWY3DPEHPK3PXP"),
+			"otpauth://totp/alice@example.com?secret=JBSWY3DPEHPK3PXP&algorithm=SHA256&digits=8&period=60&issuer=Example"},
+		{DefaultConfig.HOTPAuthURL("alice", "example.com", "JBSWY3DPEHPK3PXP", 5),
+			"otpauth://hotp/alice@example.com?secret=JBSWY3DPEHPK3PXP&counter=5"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("got %s, want %s", test.got, test.want)
+		}
+	}
+}
+
+// rfc6238Secrets are the seeds of the RFC 6238 appendix B test vectors,
+// sized to the output of each hash function, in base32.
+var rfc6238Secrets = map[Algorithm]string{
+	SHA1:   base32.StdEncoding.EncodeToString([]byte("12345678901234567890")),
+	SHA256: base32.StdEncoding.EncodeToString([]byte("12345678901234567890123456789012")),
+	SHA512: base32.StdEncoding.EncodeToString([]byte("1234567890123456789012345678901234567890123456789012345678901234")),
+}
+
+// rfc6238Vectors are the test vectors of RFC 6238 appendix B.
+var rfc6238Vectors = []struct {
+	time      int64
+	algorithm Algorithm
+	code      string
+}{
+	{59, SHA1, "94287082"},
+	{59, SHA256, "46119246"},
+	{59, SHA512, "90693936"},
+	{1111111109, SHA1, "07081804"},
+	{1111111109, SHA256, "68084774"},
+	{1111111109, SHA512, "25091201"},
+	{1111111111, SHA1, "14050471"},
+	{1111111111, SHA256, "67062674"},
+	{1111111111, SHA512, "99943326"},
+	{1234567890, SHA1, "89005924"},
+	{1234567890, SHA256, "91819424"},
+	{1234567890, SHA512, "93441116"},
+	{2000000000, SHA1, "69279037"},
+	{2000000000, SHA256, "90698825"},
+	{2000000000, SHA512, "38618901"},
+	{20000000000, SHA1, "65353130"},
+	{20000000000, SHA256, "77737706"},
+	{20000000000, SHA512, "47863826"},
+}
+
+func TestRFC6238(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		cfg := Config{Algorithm: v.algorithm, Digits: 8, Period: 30}
+		code, err := cfg.GenerateTimeBasedAt(rfc6238Secrets[v.algorithm], time.Unix(v.time, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != v.code {
+			t.Errorf("%s at %d: got %s, want %s", v.algorithm, v.time, code, v.code)
+		}
+	}
+}
+
+func TestGenerateCodeHashFunc(t *testing.T) {
+	hashFuncs := map[Algorithm]func() hash.Hash{SHA1: sha1.New, SHA256: sha256.New, SHA512: sha512.New}
+	for _, v := range rfc6238Vectors {
+		key, err := base32.StdEncoding.DecodeString(rfc6238Secrets[v.algorithm])
+		if err != nil {
+			t.Fatal(err)
+		}
+		code := generateCode(hashFuncs[v.algorithm], key, uint64(v.time/30), 8, Numeric)
+		if code != v.code {
+			t.Errorf("%s at %d: got %s, want %s", v.algorithm, v.time, code, v.code)
+		}
+	}
+}
+
+func TestParseAlgorithm(t *testing.T) {
+	for _, s := range []string{"SHA1", "sha256", "Sha512"} {
+		if _, err := ParseAlgorithm(s); err != nil {
+			t.Errorf("ParseAlgorithm(%q): %v", s, err)
+		}
+	}
+	if _, err := ParseAlgorithm("MD5"); err == nil {
+		t.Error("ParseAlgorithm(MD5) succeeded")
+	}
+}
+
+// rfc4226Values are the truncated HMAC values of RFC 4226 appendix D
+// for the counters 0 to 9, of which a code keeps the last digits.
+var rfc4226Values = []string{
+	"1284755224", "1094287082", "0137359152", "1726969429", "1640338314",
+	"0868254676", "1918287922", "0082162583", "0673399871", "0645520489",
+}
+
+func TestRFC4226Digits(t *testing.T) {
+	for _, digits := range []int{6, 7, 8} {
+		cfg := Config{Digits: digits}
+		for counter, value := range rfc4226Values {
+			code, err := cfg.GenerateCode(rfc4226Secret, uint64(counter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := value[len(value)-digits:]; code != want {
+				t.Errorf("%d digits, counter %d: got %s, want %s", digits, counter, code, want)
+			}
+		}
+	}
+	for _, digits := range []int{5, 9} {
+		if _, err := (Config{Digits: digits}).GenerateCode(rfc4226Secret, 0); err == nil {
+			t.Errorf("GenerateCode with %d digits succeeded", digits)
+		}
+	}
+}
+
+// TestPeriod60 checks the RFC 6238 vectors with a 60 second period: at
+// twice the time of a vector, the time step and so the code are the
+// same as with 30 seconds.
+func TestPeriod60(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		if v.algorithm != SHA1 {
+			continue
+		}
+		cfg := Config{Digits: 8, Period: 60}
+		code, err := cfg.GenerateTimeBasedAt(rfc6238Secrets[SHA1], time.Unix(2*v.time, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != v.code {
+			t.Errorf("at %d: got %s, want %s", 2*v.time, code, v.code)
+		}
+	}
+	cfg := Config{Period: 60}
+	if got := cfg.Expiry(time.Unix(61, 0)); got.Unix() != 120 {
+		t.Errorf("Expiry(61) = %d, want 120", got.Unix())
+	}
+}
+
+func TestVerifyTimeBasedWindow(t *testing.T) {
+	at := time.Unix(1234567890, 0)
+	for offset := -2; offset <= 2; offset++ {
+		code, _ := DefaultConfig.GenerateTimeBasedAt(rfc4226Secret, at.Add(time.Duration(offset)*30*time.Second))
+		_, ok, err := DefaultConfig.VerifyTimeBasedAt(rfc4226Secret, code, 1, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := offset >= -1 && offset <= 1; ok != want {
+			t.Errorf("window 1, code of step %+d: ok = %v, want %v", offset, ok, want)
+		}
+	}
+}
+
+func TestVerifyTimeBasedOffset(t *testing.T) {
+	at := time.Unix(1234567890, 0)
+	for step := -3; step <= 3; step++ {
+		code, _ := DefaultConfig.GenerateTimeBasedAt(rfc4226Secret, at.Add(time.Duration(step)*30*time.Second))
+		offset, ok, err := DefaultConfig.VerifyTimeBasedAt(rfc4226Secret, code, 3, at)
+		if err != nil || !ok || offset != step {
+			t.Errorf("code of step %+d: offset = %d, %v, %v", step, offset, ok, err)
+		}
+	}
+}
+
+// TestHOTP verifies each RFC 4226 appendix D code from the counter
+// before it and checks that hotp key URIs carry no period.
+func TestHOTP(t *testing.T) {
+	for counter, value := range rfc4226Values {
+		code := value[len(value)-6:]
+		next, err := DefaultConfig.VerifyCounterBased(rfc4226Secret, code, counter-1, 1)
+		if err != nil || next != counter {
+			t.Errorf("code %s: VerifyCounterBased = %d, %v, want %d", code, next, err, counter)
+		}
+	}
+	cfg := Config{Period: 60}
+	want := "otpauth://hotp/alice@example.com?secret=JBSWY3DPEHPK3PXP&counter=0"
+	if got := cfg.HOTPAuthURL("alice", "example.com", "JBSWY3DPEHPK3PXP", 0); got != want {
+		t.Errorf("HOTPAuthURL = %s, want %s", got, want)
+	}
+}
+
+func TestGenerateSecretKeyN(t *testing.T) {
+	for _, bits := range []int{-5, 0, 7, 75, 81, 128, 161} {
+		if secret, err := GenerateSecretKeyN(bits); err == nil {
+			t.Errorf("GenerateSecretKeyN(%d) = %s, want an error", bits, secret)
+		}
+	}
+	tests := []struct {
+		bits, length int
+	}{
+		{80, 16},
+		{160, 32},
+		{200, 40},
+		{255, 52},
+		{320, 64},
+	}
+	for _, test := range tests {
+		secret, err := GenerateSecretKeyN(test.bits)
+		if err != nil {
+			t.Errorf("GenerateSecretKeyN(%d): %v", test.bits, err)
+			continue
+		}
+		if len(secret) != test.length {
+			t.Errorf("GenerateSecretKeyN(%d) = %s, want %d characters", test.bits, secret, test.length)
+		}
+		if err := ValidateSecret(secret); err != nil {
+			t.Errorf("GenerateSecretKeyN(%d) = %s: %v", test.bits, secret, err)
+		}
+	}
+}
+
+func TestOTPAuthURLIssuer(t *testing.T) {
+	cfg := Config{Issuer: "ACME & Co/Labs"}
+	u, err := url.Parse(cfg.OTPAuthURL("alice", "example.com", "JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if got := query.Get("issuer"); got != cfg.Issuer {
+		t.Errorf("issuer = %q, want %q", got, cfg.Issuer)
+	}
+	if got := query.Get("secret"); got != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("secret = %q, want JBSWY3DPEHPK3PXP", got)
+	}
+	if len(query) != 2 {
+		t.Errorf("query = %v, want only secret and issuer", query)
+	}
+}
+
+func TestOTPAuthURLLabel(t *testing.T) {
+	tests := []struct {
+		user, domain, label string
+	}{
+		{"john doe", "example.com", "john%20doe@example.com"},
+		{"user+tag", "example.com", "user+tag@example.com"},
+		{"user/sub", "example.com", "user%2Fsub@example.com"},
+		{"alice@home", "a:b", "alice%40home@a%3Ab"},
+	}
+	for _, test := range tests {
+		got := DefaultConfig.OTPAuthURL(test.user, test.domain, "JBSWY3DPEHPK3PXP")
+		if want := "otpauth://totp/" + test.label + "?secret=JBSWY3DPEHPK3PXP"; got != want {
+			t.Errorf("OTPAuthURL(%q, %q) = %s, want %s", test.user, test.domain, got, want)
+		}
+		otp, err := ParseOTPAuthURL(got)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := test.user + "@" + test.domain; otp.Label != want {
+			t.Errorf("label of %s = %q, want %q", got, otp.Label, want)
+		}
+	}
+}
+
+func TestValidateSecret(t *testing.T) {
+	tests := []struct {
+		secret string
+		want   error
+	}{
+		{"JBSWY3DPEHPK3PXP", nil},
+		{"jbsw y3dp ehpk 3pxp", nil},
+		{"MZXW6===", nil},
+		{"MZXW6", nil},
+		{"MZXW6YQ=", nil},
+		{"", ErrEmptySecret},
+		{"    ", ErrEmptySecret},
+		{"JBSW03DP", ErrInvalidCharacter{Char: '0', Index: 4}},
+		{"JBSW1", ErrInvalidCharacter{Char: '1', Index: 4}},
+		{"JBSWé", ErrInvalidCharacter{Char: 'é', Index: 4}},
+		{"MZ=XW6==", ErrInvalidCharacter{Char: '=', Index: 2}},
+		{"========", ErrInvalidCharacter{Char: '=', Index: 0}},
+		{"JBSWY3DPE", ErrInvalidLength{Got: 9, RequiredMod: 8}},
+		{"JBS", ErrInvalidLength{Got: 3, RequiredMod: 8}},
+		{"JBSWY3", ErrInvalidLength{Got: 6, RequiredMod: 8}},
+		{"MZXW6=", ErrPaddingRequired},
+		{"MZXW6====", ErrPaddingRequired},
+	}
+	for _, test := range tests {
+		err := ValidateSecret(test.secret)
+		if err != test.want {
+			t.Errorf("ValidateSecret(%q) = %v, want %v", test.secret, err, test.want)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidSecret) {
+			t.Errorf("ValidateSecret(%q) = %v, not an ErrInvalidSecret", test.secret, err)
+		}
+	}
+
+	var invalid ErrInvalidCharacter
+	if err := ValidateSecret("AB8C"); !errors.As(err, &invalid) || invalid.Char != '8' || invalid.Index != 2 {
+		t.Errorf("ValidateSecret(AB8C) = %v", err)
+	}
+}
+
+func TestNormalizeSecret(t *testing.T) {
+	got, err := NormalizeSecret("mzxw 6")
+	if err != nil || got != "MZXW6===" {
+		t.Errorf("NormalizeSecret = %q, %v, want MZXW6===", got, err)
+	}
+	if _, err := NormalizeSecret("MZXW1"); err == nil {
+		t.Error("NormalizeSecret of an invalid secret succeeded")
+	}
+}
+
+func TestSteam(t *testing.T) {
+	for _, digits := range []int{0, 5, 6, 7, 9} {
+		cfg := Config{Format: Steam, Digits: digits}
+		code, err := cfg.GenerateCode("JBSWY3DPEHPK3PXP", 0)
+		if err != nil || code != "VH8YJ" {
+			t.Errorf("digits %d: GenerateCode = %q, %v, want VH8YJ", digits, code, err)
+		}
+		if next, err := cfg.VerifyCounterBased("JBSWY3DPEHPK3PXP", "VH8YJ", -1, 1); err != nil || next != 0 {
+			t.Errorf("digits %d: VerifyCounterBased = %d, %v, want 0", digits, next, err)
+		}
+	}
+
+	cfg := Config{Format: Steam, Digits: 7, Period: 30}
+	at := time.Unix(59, 0)
+	code, err := cfg.GenerateTimeBasedAt("JBSWY3DPEHPK3PXP", at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(code) != 5 || strings.Trim(code, steamAlphabet) != "" {
+		t.Errorf("time-based code %q is not a Steam Guard code", code)
+	}
+	if _, ok, err := cfg.VerifyTimeBasedAt("JBSWY3DPEHPK3PXP", code, 0, at); !ok || err != nil {
+		t.Errorf("VerifyTimeBasedAt(%q) = %v, %v", code, ok, err)
+	}
+	for _, bad := range []string{"VH8Y", "VH8YJX", "vh8yj", "12345"} {
+		if _, _, err := cfg.VerifyTimeBasedAt("JBSWY3DPEHPK3PXP", bad, 0, at); !errors.Is(err, ErrInvalidCode) {
+			t.Errorf("VerifyTimeBasedAt(%q) returned %v, want ErrInvalidCode", bad, err)
+		}
+	}
+}
+
+func TestBlizzard(t *testing.T) {
+	vectors := []struct {
+		time int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+	}
+	for _, digits := range []int{0, 6, 8} {
+		cfg := Config{Format: Blizzard, Digits: digits}
+		for _, v := range vectors {
+			at := time.Unix(v.time, 0)
+			code, err := cfg.GenerateTimeBasedAt(rfc4226Secret, at)
+			if err != nil || code != v.code {
+				t.Errorf("digits %d at %d: %q, %v, want %s", digits, v.time, code, err, v.code)
+			}
+			if _, ok, err := cfg.VerifyTimeBasedAt(rfc4226Secret, v.code, 0, at); !ok || err != nil {
+				t.Errorf("digits %d at %d: verifying %s = %v, %v", digits, v.time, v.code, ok, err)
+			}
+		}
+	}
+	if _, _, err := (Config{Format: Blizzard}).VerifyTimeBasedAt(rfc4226Secret, "287082", 0, time.Unix(59, 0)); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("verifying a 6 digit code returned %v, want ErrInvalidCode", err)
+	}
+}
@@ -0,0 +1,264 @@
+package gauth
+
+import (
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// MigrationAccount is an account as carried by the otpauth-migration://
+// URLs of Google Authenticator's "Transfer accounts" feature.
+type MigrationAccount struct {
+	Secret    string // base32 encoded
+	Name      string
+	Issuer    string
+	Algorithm Algorithm
+	Digits    int
+	Type      string // "totp" or "hotp"
+	Counter   uint64
+}
+
+// Field numbers and enum values of the MigrationPayload protobuf message.
+const (
+	payloadOTPParameters = 1
+	payloadVersion       = 2
+	payloadBatchSize     = 3
+	payloadBatchIndex    = 4
+
+	paramSecret    = 1
+	paramName      = 2
+	paramIssuer    = 3
+	paramAlgorithm = 4
+	paramDigits    = 5
+	paramType      = 6
+	paramCounter   = 7
+
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var migrationAlgorithms = map[Algorithm]uint64{"": 1, SHA1: 1, SHA256: 2, SHA512: 3}
+
+var migrationDigits = map[int]uint64{0: 1, 6: 1, 8: 2}
+
+var migrationTypes = map[string]uint64{"hotp": 1, "": 2, "totp": 2}
+
+// MigrationURL encodes accounts as a single otpauth-migration:// URL.
+func MigrationURL(accounts []MigrationAccount) (string, error) {
+	var payload []byte
+	for _, acct := range accounts {
+		params, err := encodeMigrationAccount(acct)
+		if err != nil {
+			return "", err
+		}
+		payload = appendBytesField(payload, payloadOTPParameters, params)
+	}
+	payload = appendVarintField(payload, payloadVersion, 1)
+	payload = appendVarintField(payload, payloadBatchSize, 1)
+	payload = appendVarintField(payload, payloadBatchIndex, 0)
+
+	data := base64.StdEncoding.EncodeToString(payload)
+	return "otpauth-migration://offline?data=" + url.QueryEscape(data), nil
+}
+
+func encodeMigrationAccount(acct MigrationAccount) ([]byte, error) {
+	secret, err := decodeSecret(acct.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("gauth: account %q: %w", acct.Name, err)
+	}
+	algorithm, ok := migrationAlgorithms[acct.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("gauth: account %q: algorithm %s can not be exported", acct.Name, acct.Algorithm)
+	}
+	digits, ok := migrationDigits[acct.Digits]
+	if !ok {
+		return nil, fmt.Errorf("gauth: account %q: %d digits can not be exported", acct.Name, acct.Digits)
+	}
+	otpType, ok := migrationTypes[acct.Type]
+	if !ok {
+		return nil, fmt.Errorf("gauth: account %q: unknown type %q", acct.Name, acct.Type)
+	}
+
+	var params []byte
+	params = appendBytesField(params, paramSecret, secret)
+	params = appendBytesField(params, paramName, []byte(acct.Name))
+	if acct.Issuer != "" {
+		params = appendBytesField(params, paramIssuer, []byte(acct.Issuer))
+	}
+	params = appendVarintField(params, paramAlgorithm, algorithm)
+	params = appendVarintField(params, paramDigits, digits)
+	params = appendVarintField(params, paramType, otpType)
+	if acct.Counter != 0 {
+		params = appendVarintField(params, paramCounter, acct.Counter)
+	}
+	return params, nil
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	b = appendVarint(b, uint64(field)<<3|wireVarint)
+	return appendVarint(b, v)
+}
+
+func appendBytesField(b []byte, field int, v []byte) []byte {
+	b = appendVarint(b, uint64(field)<<3|wireBytes)
+	b = appendVarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+var errMalformedPayload = errors.New("gauth: malformed migration payload")
+
+// ParseMigrationURL decodes the accounts carried by an
+// otpauth-migration:// URL.
+func ParseMigrationURL(u string) ([]MigrationAccount, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("gauth: invalid migration URL: %w", err)
+	}
+	if parsed.Scheme != "otpauth-migration" {
+		return nil, fmt.Errorf("gauth: invalid migration URL scheme %q", parsed.Scheme)
+	}
+	data := parsed.Query().Get("data")
+	if data == "" {
+		return nil, errors.New("gauth: migration URL has no data")
+	}
+	payload, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		payload, err = base64.RawStdEncoding.DecodeString(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gauth: invalid migration data: %w", err)
+	}
+
+	var accounts []MigrationAccount
+	err = parseFields(payload, func(field int, varint uint64, data []byte) error {
+		if field != payloadOTPParameters || data == nil {
+			return nil
+		}
+		acct, err := decodeMigrationAccount(data)
+		if err != nil {
+			return err
+		}
+		accounts = append(accounts, acct)
+		return nil
+	})
+	return accounts, err
+}
+
+func decodeMigrationAccount(params []byte) (MigrationAccount, error) {
+	var acct MigrationAccount
+	var secret []byte
+	var algorithm, digits, otpType uint64
+	err := parseFields(params, func(field int, varint uint64, data []byte) error {
+		switch field {
+		case paramSecret:
+			secret = data
+		case paramName:
+			acct.Name = string(data)
+		case paramIssuer:
+			acct.Issuer = string(data)
+		case paramAlgorithm:
+			algorithm = varint
+		case paramDigits:
+			digits = varint
+		case paramType:
+			otpType = varint
+		case paramCounter:
+			acct.Counter = varint
+		}
+		return nil
+	})
+	if err != nil {
+		return acct, err
+	}
+
+	if len(secret) == 0 {
+		return acct, fmt.Errorf("gauth: account %q has no secret", acct.Name)
+	}
+	acct.Secret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+	switch algorithm {
+	case 0, 1:
+		acct.Algorithm = SHA1
+	case 2:
+		acct.Algorithm = SHA256
+	case 3:
+		acct.Algorithm = SHA512
+	default:
+		return acct, fmt.Errorf("gauth: account %q uses unsupported algorithm %d", acct.Name, algorithm)
+	}
+	switch digits {
+	case 0, 1:
+		acct.Digits = 6
+	case 2:
+		acct.Digits = 8
+	default:
+		return acct, fmt.Errorf("gauth: account %q uses unsupported digit count %d", acct.Name, digits)
+	}
+	switch otpType {
+	case 0, 2:
+		acct.Type = "totp"
+	case 1:
+		acct.Type = "hotp"
+	default:
+		return acct, fmt.Errorf("gauth: account %q uses unsupported type %d", acct.Name, otpType)
+	}
+	return acct, nil
+}
+
+// parseFields calls fn for every field of a protobuf message, passing
+// the value of varint fields or the contents of length-delimited ones.
+func parseFields(b []byte, fn func(field int, varint uint64, data []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errMalformedPayload
+		}
+		b = b[n:]
+		field := int(key >> 3)
+		var varint uint64
+		var data []byte
+		switch key & 7 {
+		case wireVarint:
+			varint, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errMalformedPayload
+			}
+			b = b[n:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return errMalformedPayload
+			}
+			data = b[n : n+int(size)]
+			b = b[n+int(size):]
+		case wireFixed64, wireFixed32:
+			size := 8
+			if key&7 == wireFixed32 {
+				size = 4
+			}
+			if len(b) < size {
+				return errMalformedPayload
+			}
+			b = b[size:]
+			continue
+		default:
+			return errMalformedPayload
+		}
+		if err := fn(field, varint, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package gauth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"testing"
+)
+
+func TestMigrationURL(t *testing.T) {
+	u, err := MigrationURL([]MigrationAccount{
+		{Secret: "JBSWY3DPEHPK3PXP", Name: "alice@example.com", Issuer: "Example"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Scheme != "otpauth-migration" || parsed.Host != "offline" {
+		t.Fatalf("MigrationURL = %s", u)
+	}
+	payload, err := base64.StdEncoding.DecodeString(parsed.Query().Get("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := []byte{
+		0x0a, 10, 'H', 'e', 'l', 'l', 'o', '!', 0xde, 0xad, 0xbe, 0xef,
+		0x12, 17, 'a', 'l', 'i', 'c', 'e', '@', 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm',
+		0x1a, 7, 'E', 'x', 'a', 'm', 'p', 'l', 'e',
+		0x20, 1, // SHA1
+		0x28, 1, // six digits
+		0x30, 2, // TOTP
+	}
+	want := append([]byte{0x0a, byte(len(params))}, params...)
+	want = append(want, 0x10, 1, 0x18, 1, 0x20, 0)
+	if !bytes.Equal(payload, want) {
+		t.Errorf("payload = % x, want % x", payload, want)
+	}
+
+	if _, err := MigrationURL([]MigrationAccount{{Secret: "JBSWY3DPEHPK3PXP", Digits: 7}}); err == nil {
+		t.Error("MigrationURL with 7 digits succeeded")
+	}
+}
+
+func TestParseMigrationURL(t *testing.T) {
+	// An HOTP account with SHA256 and eight digits, preceded by an
+	// unknown fixed32 field, then the version, batch size and index.
+	params := []byte{
+		0x0a, 10, 'H', 'e', 'l', 'l', 'o', '!', 0xde, 0xad, 0xbe, 0xef,
+		0x12, 3, 'b', 'o', 'b',
+		0x20, 2, // SHA256
+		0x28, 2, // eight digits
+		0x30, 1, // HOTP
+		0x38, 0xac, 0x02, // counter 300
+	}
+	payload := []byte{0x4d, 1, 2, 3, 4, 0x0a, byte(len(params))}
+	payload = append(payload, params...)
+	payload = append(payload, 0x10, 1, 0x18, 1, 0x20, 0)
+	data := base64.RawStdEncoding.EncodeToString(payload)
+
+	accounts, err := ParseMigrationURL("otpauth-migration://offline?data=" + url.QueryEscape(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := MigrationAccount{
+		Secret: "JBSWY3DPEHPK3PXP", Name: "bob", Algorithm: SHA256,
+		Digits: 8, Type: "hotp", Counter: 300,
+	}
+	if len(accounts) != 1 || accounts[0] != want {
+		t.Errorf("ParseMigrationURL = %+v, want %+v", accounts, want)
+	}
+
+	exported, err := MigrationURL(accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reparsed, err := ParseMigrationURL(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reparsed) != 1 || reparsed[0] != want {
+		t.Errorf("round trip = %+v, want %+v", reparsed, want)
+	}
+
+	for _, u := range []string{
+		"otpauth://totp/bob?secret=JBSWY3DPEHPK3PXP",
+		"otpauth-migration://offline",
+		"otpauth-migration://offline?data=CgUKAw", // truncated account
+	} {
+		if _, err := ParseMigrationURL(u); err == nil {
+			t.Errorf("ParseMigrationURL(%q) succeeded", u)
+		}
+	}
+}
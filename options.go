@@ -0,0 +1,72 @@
+package gauth
+
+import "time"
+
+// Option changes a setting of the Config used by Generate and Verify.
+type Option func(*Config)
+
+// WithAlgorithm sets the HMAC algorithm.
+func WithAlgorithm(a Algorithm) Option {
+	return func(c *Config) { c.Algorithm = a }
+}
+
+// WithDigits sets the number of digits of a code, 6 to 8.
+func WithDigits(digits int) Option {
+	return func(c *Config) { c.Digits = digits }
+}
+
+// WithPeriod sets the number of seconds a code is valid for.
+func WithPeriod(seconds uint) Option {
+	return func(c *Config) { c.Period = seconds }
+}
+
+// WithWindow sets the number of time steps Verify checks on each side of
+// the current one: a window of n accepts the 2n+1 codes of the steps
+// from n before to n after the current step. The default of 1 accepts
+// the previous, current and next code; 0 accepts only the current one.
+// Wider windows give replayed and guessed codes longer to pass.
+func WithWindow(steps int) Option {
+	return func(c *Config) { c.Window = steps }
+}
+
+// WithReplayStore makes Verify record the time step of each accepted
+// code in store, keyed by ReplayKey of the secret, and refuse codes of
+// earlier or the same steps.
+func WithReplayStore(store ReplayStore) Option {
+	return func(c *Config) { c.Replay = store }
+}
+
+// WithTime makes codes be generated and verified for t instead of the
+// current time.
+func WithTime(t time.Time) Option {
+	return func(c *Config) { c.TimeFunc = func() time.Time { return t } }
+}
+
+// WithTimeFunc makes codes be generated and verified for the times
+// returned by now instead of the current time, for example in tests.
+func WithTimeFunc(now func() time.Time) Option {
+	return func(c *Config) { c.TimeFunc = now }
+}
+
+// newConfig applies opts to a copy of DefaultConfig.
+func newConfig(opts []Option) Config {
+	c := DefaultConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// Generate returns the time-based code for secret at the current time,
+// using DefaultConfig changed by opts.
+func Generate(secret string, opts ...Option) (string, error) {
+	return NewClient(opts...).Generate(secret)
+}
+
+// Verify reports whether code is the time-based code for secret at the
+// current time, or within the window of time steps around it, using
+// DefaultConfig changed by opts. A malformed code is reported as
+// ErrInvalidCode.
+func Verify(secret, code string, opts ...Option) (bool, error) {
+	return NewClient(opts...).Verify(secret, code)
+}
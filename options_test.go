@@ -0,0 +1,40 @@
+package gauth
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestWithWindow pins the meaning of the window: the number of time
+// steps checked on each side of the current one.
+func TestWithWindow(t *testing.T) {
+	at := time.Unix(1234567890, 0)
+	tests := []struct {
+		opts     []Option
+		accepted []int // offsets, in time steps, of the codes accepted
+	}{
+		{nil, []int{-1, 0, 1}},
+		{[]Option{WithWindow(0)}, []int{0}},
+		{[]Option{WithWindow(2)}, []int{-2, -1, 0, 1, 2}},
+	}
+	for _, test := range tests {
+		var accepted []int
+		for offset := -3; offset <= 3; offset++ {
+			code, err := DefaultConfig.GenerateTimeBasedAt(rfc4226Secret, at.Add(time.Duration(offset)*30*time.Second))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ok, err := Verify(rfc4226Secret, code, append(test.opts, WithTime(at))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok {
+				accepted = append(accepted, offset)
+			}
+		}
+		if !slices.Equal(accepted, test.accepted) {
+			t.Errorf("%d options: accepted the codes of steps %v, want %v", len(test.opts), accepted, test.accepted)
+		}
+	}
+}
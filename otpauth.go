@@ -0,0 +1,100 @@
+package gauth
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// OTPConfig is an account described by an otpauth:// key URI as
+// specified for Google Authenticator. Parameters the URI leaves out
+// have their default values: SHA1, six digits and 30 seconds.
+type OTPConfig struct {
+	Type      string // "totp" or "hotp"
+	Label     string // "account" or "Issuer:account"
+	Issuer    string
+	Secret    string
+	Algorithm Algorithm
+	Digits    int
+	Period    uint
+	Counter   uint64 // hotp only
+}
+
+// ParseOTPAuthURL parses an otpauth://totp/... or otpauth://hotp/... key
+// URI. The issuer is taken from the issuer parameter, or else from the
+// "Issuer:" prefix of the label.
+func ParseOTPAuthURL(u string) (*OTPConfig, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("gauth: invalid otpauth URL: %w", err)
+	}
+	if parsed.Scheme != "otpauth" {
+		return nil, fmt.Errorf("gauth: invalid otpauth URL scheme %q", parsed.Scheme)
+	}
+	otp := &OTPConfig{
+		Type:      strings.ToLower(parsed.Host),
+		Label:     strings.TrimPrefix(parsed.Path, "/"),
+		Algorithm: DefaultConfig.Algorithm,
+		Digits:    DefaultConfig.Digits,
+		Period:    DefaultConfig.Period,
+	}
+	if otp.Type != "totp" && otp.Type != "hotp" {
+		return nil, fmt.Errorf("gauth: unsupported otpauth type %q", parsed.Host)
+	}
+
+	query := parsed.Query()
+	otp.Secret = query.Get("secret")
+	if otp.Secret == "" {
+		return nil, errors.New("gauth: otpauth URL has no secret")
+	}
+	if err := ValidateSecret(otp.Secret); err != nil {
+		return nil, err
+	}
+	otp.Issuer = query.Get("issuer")
+	if issuer, _, ok := strings.Cut(otp.Label, ":"); ok && otp.Issuer == "" {
+		otp.Issuer = strings.TrimSpace(issuer)
+	}
+	if s := query.Get("algorithm"); s != "" {
+		if otp.Algorithm, err = ParseAlgorithm(s); err != nil {
+			return nil, err
+		}
+	}
+	if s := query.Get("digits"); s != "" {
+		otp.Digits, err = strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("gauth: invalid digits %q", s)
+		}
+		if _, err := (Config{Digits: otp.Digits}).digits(); err != nil {
+			return nil, err
+		}
+	}
+	if s := query.Get("period"); s != "" {
+		period, err := strconv.ParseUint(s, 10, 0)
+		if err != nil || period == 0 {
+			return nil, fmt.Errorf("gauth: invalid period %q", s)
+		}
+		otp.Period = uint(period)
+	}
+	if otp.Type == "hotp" {
+		s := query.Get("counter")
+		if s == "" {
+			return nil, errors.New("gauth: hotp URL has no counter")
+		}
+		if otp.Counter, err = strconv.ParseUint(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("gauth: invalid counter %q", s)
+		}
+	}
+	return otp, nil
+}
+
+// Config returns the parameters of the account as a Config.
+func (otp *OTPConfig) Config() Config {
+	return Config{
+		Issuer:    otp.Issuer,
+		Algorithm: otp.Algorithm,
+		Digits:    otp.Digits,
+		Period:    otp.Period,
+	}
+}
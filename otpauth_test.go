@@ -0,0 +1,52 @@
+package gauth
+
+import "testing"
+
+func TestParseOTPAuthURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want OTPConfig
+	}{
+		{"otpauth://totp/alice@example.com?secret=JBSWY3DPEHPK3PXP",
+			OTPConfig{Type: "totp", Label: "alice@example.com", Secret: "JBSWY3DPEHPK3PXP",
+				Algorithm: SHA1, Digits: 6, Period: 30}},
+		{"otpauth://totp/ACME%20Co:alice@example.com?secret=JBSWY3DPEHPK3PXP&issuer=ACME+Co&algorithm=sha256&digits=8&period=60",
+			OTPConfig{Type: "totp", Label: "ACME Co:alice@example.com", Issuer: "ACME Co", Secret: "JBSWY3DPEHPK3PXP",
+				Algorithm: SHA256, Digits: 8, Period: 60}},
+		{"otpauth://TOTP/Example:bob?secret=JBSWY3DPEHPK3PXP",
+			OTPConfig{Type: "totp", Label: "Example:bob", Issuer: "Example", Secret: "JBSWY3DPEHPK3PXP",
+				Algorithm: SHA1, Digits: 6, Period: 30}},
+		{"otpauth://hotp/bob?secret=JBSWY3DPEHPK3PXP&counter=0",
+			OTPConfig{Type: "hotp", Label: "bob", Secret: "JBSWY3DPEHPK3PXP",
+				Algorithm: SHA1, Digits: 6, Period: 30}},
+		{"otpauth://hotp/Example:bob?secret=JBSWY3DPEHPK3PXP&issuer=Other&algorithm=SHA512&digits=7&counter=42",
+			OTPConfig{Type: "hotp", Label: "Example:bob", Issuer: "Other", Secret: "JBSWY3DPEHPK3PXP",
+				Algorithm: SHA512, Digits: 7, Period: 30, Counter: 42}},
+	}
+	for _, test := range tests {
+		otp, err := ParseOTPAuthURL(test.url)
+		if err != nil {
+			t.Errorf("ParseOTPAuthURL(%s): %v", test.url, err)
+			continue
+		}
+		if *otp != test.want {
+			t.Errorf("ParseOTPAuthURL(%s) = %+v, want %+v", test.url, *otp, test.want)
+		}
+	}
+
+	for _, u := range []string{
+		"https://example.com/?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://motp/bob?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://totp/bob",
+		"otpauth://totp/bob?secret=JBSW1",
+		"otpauth://totp/bob?secret=JBSWY3DPEHPK3PXP&algorithm=MD5",
+		"otpauth://totp/bob?secret=JBSWY3DPEHPK3PXP&digits=10",
+		"otpauth://totp/bob?secret=JBSWY3DPEHPK3PXP&period=0",
+		"otpauth://hotp/bob?secret=JBSWY3DPEHPK3PXP",
+		"otpauth://hotp/bob?secret=JBSWY3DPEHPK3PXP&counter=-1",
+	} {
+		if _, err := ParseOTPAuthURL(u); err == nil {
+			t.Errorf("ParseOTPAuthURL(%s) succeeded", u)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package qr
+
+import (
+	"image"
+	"image/color"
+)
+
+// Image draws the code as a grayscale image with each module scale
+// pixels wide, surrounded by a light quiet zone of border modules.
+func (c *Code) Image(scale, border int) *image.Gray {
+	size := (c.Size + 2*border) * scale
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			v := color.Gray{Y: 0xff}
+			if c.Black(px/scale-border, py/scale-border) {
+				v.Y = 0
+			}
+			img.SetGray(px, py, v)
+		}
+	}
+	return img
+}
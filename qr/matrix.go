@@ -0,0 +1,271 @@
+package qr
+
+// builder lays out the modules of a symbol while it is being encoded.
+type builder struct {
+	version    int
+	size       int
+	modules    []bool
+	isFunction []bool
+}
+
+func newBuilder(version int) *builder {
+	size := version*4 + 17
+	return &builder{
+		version:    version,
+		size:       size,
+		modules:    make([]bool, size*size),
+		isFunction: make([]bool, size*size),
+	}
+}
+
+func (b *builder) get(x, y int) bool {
+	return b.modules[y*b.size+x]
+}
+
+func (b *builder) setFunction(x, y int, dark bool) {
+	b.modules[y*b.size+x] = dark
+	b.isFunction[y*b.size+x] = true
+}
+
+func (b *builder) drawFunctionPatterns() {
+	for i := 0; i < b.size; i++ {
+		b.setFunction(6, i, i%2 == 0)
+		b.setFunction(i, 6, i%2 == 0)
+	}
+
+	b.drawFinderPattern(3, 3)
+	b.drawFinderPattern(b.size-4, 3)
+	b.drawFinderPattern(3, b.size-4)
+
+	positions := alignmentPatternPositions(b.version)
+	last := len(positions) - 1
+	for i, y := range positions {
+		for j, x := range positions {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue // overlaps a finder pattern
+			}
+			b.drawAlignmentPattern(x, y)
+		}
+	}
+
+	b.drawFormatBits(0, 0) // reserve the area, redrawn after masking
+	b.drawVersion()
+}
+
+// drawFinderPattern draws a finder pattern centred on (x, y) together
+// with its light separator.
+func (b *builder) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= b.size || yy >= b.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			b.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (b *builder) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			b.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormatBits draws both copies of the BCH coded level and mask.
+func (b *builder) drawFormatBits(level Level, mask int) {
+	data := formatLevelBits[level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>uint(i)&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		b.setFunction(8, i, bit(i))
+	}
+	b.setFunction(8, 7, bit(6))
+	b.setFunction(8, 8, bit(7))
+	b.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		b.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		b.setFunction(b.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		b.setFunction(8, b.size-15+i, bit(i))
+	}
+	b.setFunction(8, b.size-8, true) // dark module
+}
+
+// drawVersion draws both copies of the BCH coded version, present
+// from version 7 on.
+func (b *builder) drawVersion() {
+	if b.version < 7 {
+		return
+	}
+	rem := b.version
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * 0x1f25)
+	}
+	bits := b.version<<12 | rem
+	for i := 0; i < 18; i++ {
+		dark := bits>>uint(i)&1 != 0
+		x, y := b.size-11+i%3, i/3
+		b.setFunction(x, y, dark)
+		b.setFunction(y, x, dark)
+	}
+}
+
+// drawCodewords places the codewords in the zigzag order starting at
+// the bottom right corner, skipping function modules.
+func (b *builder) drawCodewords(data []byte) {
+	i := 0
+	for right := b.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // skip the vertical timing pattern
+		}
+		for vert := 0; vert < b.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = b.size - 1 - vert // upward column
+				}
+				if b.isFunction[y*b.size+x] || i >= len(data)*8 {
+					continue
+				}
+				b.modules[y*b.size+x] = data[i>>3]>>uint(7-i&7)&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+func (b *builder) applyMask(mask int) {
+	for y := 0; y < b.size; y++ {
+		for x := 0; x < b.size; x++ {
+			if b.isFunction[y*b.size+x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				b.modules[y*b.size+x] = !b.modules[y*b.size+x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol using the four rules of ISO/IEC 18004
+// section 7.8.3; the mask with the lowest score is used.
+func (b *builder) penalty() int {
+	result := 0
+	finderLike := [][]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+	line := make([]bool, b.size)
+	for pass := 0; pass < 2; pass++ {
+		for i := 0; i < b.size; i++ {
+			for j := 0; j < b.size; j++ {
+				if pass == 0 {
+					line[j] = b.get(j, i)
+				} else {
+					line[j] = b.get(i, j)
+				}
+			}
+			run := 1
+			for j := 1; j <= b.size; j++ {
+				if j < b.size && line[j] == line[j-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					result += run - 2
+				}
+				run = 1
+			}
+			for j := 0; j+11 <= b.size; j++ {
+				for _, pattern := range finderLike {
+					match := true
+					for k, dark := range pattern {
+						if line[j+k] != dark {
+							match = false
+							break
+						}
+					}
+					if match {
+						result += 40
+					}
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < b.size; y++ {
+		for x := 0; x < b.size; x++ {
+			if b.get(x, y) {
+				dark++
+			}
+			if x+1 < b.size && y+1 < b.size {
+				c := b.get(x, y)
+				if c == b.get(x+1, y) && c == b.get(x, y+1) && c == b.get(x+1, y+1) {
+					result += 3
+				}
+			}
+		}
+	}
+	total := b.size * b.size
+	result += abs(dark*20-total*10) / total * 10
+	return result
+}
+
+// alignmentPatternPositions returns the row and column coordinates of
+// the alignment pattern centres.
+func alignmentPatternPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	numAlign := version/7 + 2
+	step := (version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+	result := make([]int, numAlign)
+	result[0] = 6
+	for i, pos := numAlign-1, version*4+10; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+var formatLevelBits = [4]int{L: 1, M: 0, Q: 3, H: 2}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
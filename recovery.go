@@ -0,0 +1,33 @@
+package gauth
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// DefaultRecoveryCodes is the number of codes GenerateRecoveryCodes
+// returns when asked for 0.
+const DefaultRecoveryCodes = 10
+
+// GenerateRecoveryCodes returns n random one-time recovery codes of 16
+// hex digits, formatted as XXXX-XXXX-XXXX-XXXX. An n of 0 returns
+// DefaultRecoveryCodes codes.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	if n < 0 {
+		return nil, errors.New("gauth: negative number of recovery codes")
+	}
+	if n == 0 {
+		n = DefaultRecoveryCodes
+	}
+	codes := make([]string, n)
+	for i := range codes {
+		b, err := generateRandomBytes(8)
+		if err != nil {
+			return nil, err
+		}
+		digits := strings.ToUpper(hex.EncodeToString(b))
+		codes[i] = digits[0:4] + "-" + digits[4:8] + "-" + digits[8:12] + "-" + digits[12:16]
+	}
+	return codes, nil
+}
@@ -0,0 +1,66 @@
+package gauth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCodeReused is returned when a code is verified for a time step,
+// or counter value, no later than one already accepted for the same
+// account: RFC 6238 section 5.2 forbids accepting a code twice.
+var ErrCodeReused = errors.New("gauth: code already used")
+
+// ReplayStore records the highest time step accepted for each account,
+// identified by key.
+type ReplayStore interface {
+	// Accept records step for key. It returns ErrCodeReused, and
+	// records nothing, when step is not after the last step accepted
+	// for key.
+	Accept(key string, step uint64) error
+}
+
+// MemoryReplayStore is a ReplayStore held in memory, for a single
+// process. The zero value is ready to use.
+type MemoryReplayStore struct {
+	mu   sync.Mutex
+	last map[string]uint64
+}
+
+// Accept implements ReplayStore.
+func (s *MemoryReplayStore) Accept(key string, step uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.last[key]; ok && step <= last {
+		return ErrCodeReused
+	}
+	if s.last == nil {
+		s.last = make(map[string]uint64)
+	}
+	s.last[key] = step
+	return nil
+}
+
+// VerifyTimeBasedOnce is like VerifyTimeBasedAt but also records the
+// time step of a matching code for key in store. A code of a step no
+// later than one accepted before is reported as ErrCodeReused with ok
+// set to false.
+func (c Config) VerifyTimeBasedOnce(store ReplayStore, key, secret, code string, window int, t time.Time) (offset int, ok bool, err error) {
+	offset, ok, err = c.VerifyTimeBasedAt(secret, code, window, t)
+	if !ok || err != nil {
+		return offset, ok, err
+	}
+	if err := store.Accept(key, c.TimeStep(t)+uint64(offset)); err != nil {
+		return offset, false, err
+	}
+	return offset, true, nil
+}
+
+// ReplayKey returns the key of an account identified only by its
+// secret: a hash of it, so that stores never hold the secret itself.
+func ReplayKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,59 @@
+package gauth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryReplayStore(t *testing.T) {
+	var store MemoryReplayStore
+	steps := []struct {
+		key  string
+		step uint64
+		want error
+	}{
+		{"a", 10, nil},
+		{"a", 10, ErrCodeReused},
+		{"a", 9, ErrCodeReused},
+		{"b", 9, nil},
+		{"a", 11, nil},
+		{"a", 10, ErrCodeReused},
+	}
+	for _, s := range steps {
+		if err := store.Accept(s.key, s.step); err != s.want {
+			t.Errorf("Accept(%s, %d) = %v, want %v", s.key, s.step, err, s.want)
+		}
+	}
+}
+
+// TestClientReplay checks that a client with a replay store refuses a
+// code it accepted and, once it accepted the code of the next step,
+// the still valid code of the current one.
+func TestClientReplay(t *testing.T) {
+	at := time.Unix(1234567890, 0)
+	current, _ := DefaultConfig.GenerateTimeBasedAt(rfc4226Secret, at)
+	next, _ := DefaultConfig.GenerateTimeBasedAt(rfc4226Secret, at.Add(30*time.Second))
+	c := NewClient(WithTime(at), WithReplayStore(&MemoryReplayStore{}))
+
+	if ok, err := c.Verify(rfc4226Secret, current); !ok || err != nil {
+		t.Fatalf("first use of the current code: %v, %v", ok, err)
+	}
+	if ok, err := c.Verify(rfc4226Secret, current); ok || !errors.Is(err, ErrCodeReused) {
+		t.Errorf("replay of the current code: %v, %v, want ErrCodeReused", ok, err)
+	}
+	if ok, err := c.Verify(rfc4226Secret, next); !ok || err != nil {
+		t.Errorf("code of the next step: %v, %v", ok, err)
+	}
+	if ok, err := c.Verify("JBSWY3DPEHPK3PXP", "000000"); ok || err != nil {
+		t.Errorf("wrong code: %v, %v, want false and no error", ok, err)
+	}
+
+	// Without a store, codes can be verified again.
+	plain := NewClient(WithTime(at))
+	for range 2 {
+		if ok, err := plain.Verify(rfc4226Secret, current); !ok || err != nil {
+			t.Errorf("without a store: %v, %v", ok, err)
+		}
+	}
+}